@@ -0,0 +1,91 @@
+package command_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+var errCorrupt = errors.New("corrupt checksum")
+
+// AbortProgram aborts the run on the record "bad" and counts the End calls
+type AbortProgram struct {
+	command.SimpleProgram
+	ends *int
+}
+
+func (p AbortProgram) Action(ctx *command.Context) (string, bool) {
+	if ctx.Field(0) == "bad" {
+		ctx.Abort(errCorrupt)
+		ctx.Abort(errors.New("ignored"))
+	}
+	return ctx.Field(0), true
+}
+
+func (p AbortProgram) End(ctx *command.Context) (string, error) {
+	*p.ends++
+	return "end", nil
+}
+
+func TestContext_Abort(t *testing.T) {
+	var ends int
+	result := run.Command(command.Awk(AbortProgram{ends: &ends})).
+		WithStdinLines("a", "bad", "c").Run()
+
+	assertion.True(t, errors.Is(result.Err, errCorrupt), "the run fails with the Abort error")
+	assertion.ErrorContains(t, result.Err, "record 2: corrupt checksum")
+	assertion.Lines(t, result.Stdout, []string{"a"})
+	assertion.Equal(t, ends, 0, "End is skipped")
+}
+
+func TestContext_Abort_File(t *testing.T) {
+	var ends int
+	path := writeFile(t, "data.txt", "a", "bad")
+	result := run.Command(command.Awk(AbortProgram{ends: &ends}, path)).Run()
+
+	assertion.ErrorContains(t, result.Err, "record 2 ("+path+"): corrupt checksum")
+}
+
+// AbortInProgram aborts from Begin or End
+type AbortInProgram struct {
+	command.SimpleProgram
+	begin bool
+}
+
+func (p AbortInProgram) Begin(ctx *command.Context) error {
+	if p.begin {
+		ctx.Abort(nil)
+	}
+	return nil
+}
+
+func (p AbortInProgram) End(ctx *command.Context) (string, error) {
+	ctx.Abort(errCorrupt)
+	return "dropped", nil
+}
+
+func TestContext_Abort_BeginEnd(t *testing.T) {
+	result := run.Command(command.Awk(AbortInProgram{begin: true})).WithStdinLines("a").Run()
+
+	assertion.True(t, errors.Is(result.Err, command.ErrAborted), "nil aborts with ErrAborted")
+	assertion.ErrorContains(t, result.Err, "BEGIN: aborted")
+	assertion.Empty(t, result.Stdout)
+
+	result = run.Command(command.Awk(AbortInProgram{})).WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "END: corrupt checksum")
+	assertion.Lines(t, result.Stdout, []string{"a"})
+}
+
+func TestContext_Abort_Pipe(t *testing.T) {
+	var ends int
+	result := run.Command(command.Pipe(command.SimpleProgram{}, AbortProgram{ends: &ends})).
+		WithStdinLines("a", "bad", "c").Run()
+
+	assertion.ErrorContains(t, result.Err, "stage 2: record 2: corrupt checksum")
+	assertion.Lines(t, result.Stdout, []string{"a"})
+	assertion.Equal(t, ends, 0, "End is skipped")
+}
@@ -0,0 +1,140 @@
+package command
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Array is an awk associative array, like count in count[$1]++: a map from
+// string keys to values that remembers the order in which keys were added.
+// Get one with ctx.ArrayVar; it is stored in Variables, so it lasts across
+// records and is there in End. With SharedVariables its methods are safe for
+// concurrent use.
+type Array struct {
+	mu     *sync.Mutex
+	keys   []string
+	values map[string]any
+}
+
+// ArrayVar returns the Array stored in the variable name, creating it empty
+// when the variable is unset. A variable holding anything else aborts the
+// run, as using a scalar as an array does in awk, and an empty Array that is
+// not stored is returned.
+func (c *Context) ArrayVar(name string) *Array {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if c.Variables == nil {
+		c.Variables = make(map[string]any)
+	}
+	switch v := c.Variables[name].(type) {
+	case *Array:
+		return v
+	case nil:
+		a := &Array{mu: c.mu}
+		c.Variables[name] = a
+		return a
+	default:
+		if c.abort == nil {
+			c.abort = fmt.Errorf("variable %s is %T, not an Array", name, v)
+		}
+		return &Array{}
+	}
+}
+
+// lock locks the Array if it is shared, and returns the function unlocking it
+func (a *Array) lock() func() {
+	if a.mu == nil {
+		return func() {}
+	}
+	a.mu.Lock()
+	return a.mu.Unlock
+}
+
+// Get returns the value of key, or nil if the Array does not contain it.
+// Unlike awk, reading a key does not add it.
+func (a *Array) Get(key string) any {
+	defer a.lock()()
+	return a.values[key]
+}
+
+// Set sets the value of key, like a[key] = value
+func (a *Array) Set(key string, value any) {
+	defer a.lock()()
+	a.set(key, value)
+}
+
+// Incr adds delta to the number at key and returns the new value, like
+// a[key] += delta; a missing key starts at 0. As with AddVar, values stay
+// int when delta is integral and other values count as VarFloat reads them.
+func (a *Array) Incr(key string, delta float64) float64 {
+	defer a.lock()()
+	sum := add(a.values[key], delta)
+	a.set(key, sum)
+	switch v := sum.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v.(float64)
+	}
+}
+
+// set sets key, adding it after the other keys if it is new
+func (a *Array) set(key string, value any) {
+	if a.values == nil {
+		a.values = make(map[string]any)
+	}
+	if _, ok := a.values[key]; !ok {
+		a.keys = append(a.keys, key)
+	}
+	a.values[key] = value
+}
+
+// Delete removes key, like delete a[key]
+func (a *Array) Delete(key string) {
+	defer a.lock()()
+	if _, ok := a.values[key]; !ok {
+		return
+	}
+	delete(a.values, key)
+	i := slices.Index(a.keys, key)
+	a.keys = slices.Delete(a.keys, i, i+1)
+}
+
+// Contains reports whether the Array contains key, like (key in a)
+func (a *Array) Contains(key string) bool {
+	defer a.lock()()
+	_, ok := a.values[key]
+	return ok
+}
+
+// Len returns the number of keys, like length(a)
+func (a *Array) Len() int {
+	defer a.lock()()
+	return len(a.keys)
+}
+
+// Keys returns a copy of the keys in the order they were added, or sorted
+// like SortedByKey when sorted is true, for loops like for (k in a)
+func (a *Array) Keys(sorted bool) []string {
+	defer a.lock()()
+	keys := slices.Clone(a.keys)
+	if sorted {
+		sortKeys(keys)
+	}
+	return keys
+}
+
+// entries returns the entries of the Array in the order they were added
+func (a *Array) entries() []Entry[any] {
+	defer a.lock()()
+	entries := make([]Entry[any], len(a.keys))
+	for i, k := range a.keys {
+		entries[i] = Entry[any]{Key: k, Value: a.values[k]}
+	}
+	return entries
+}
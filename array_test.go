@@ -0,0 +1,117 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestArray(t *testing.T) {
+	ctx := &command.Context{}
+	a := ctx.ArrayVar("a")
+	assertion.True(t, ctx.ArrayVar("a") == a, "same Array on every call")
+	assertion.Equal(t, a.Len(), 0, "empty")
+	assertion.True(t, a.Get("x") == nil, "missing key")
+	assertion.False(t, a.Contains("x"), "reading does not add the key")
+
+	a.Set("b", "bee")
+	assertion.Equal(t, a.Incr("a", 1), 1.0, "missing key starts at 0")
+	assertion.Equal(t, a.Incr("a", 2), 3.0, "incremented")
+	assertion.Equal(t, a.Incr("c", 0.5), 0.5, "fraction")
+	assertion.Equal(t, a.Get("a"), 3, "integral increments stay int")
+	assertion.Equal(t, a.Get("b"), "bee", "set value")
+
+	assertion.Equal(t, a.Keys(false), []string{"b", "a", "c"}, "insertion order")
+	assertion.Equal(t, a.Keys(true), []string{"a", "b", "c"}, "sorted")
+
+	a.Set("b", "bumble")
+	assertion.Equal(t, a.Keys(false), []string{"b", "a", "c"}, "setting a key keeps its place")
+
+	a.Delete("b")
+	a.Delete("missing")
+	assertion.False(t, a.Contains("b"), "deleted")
+	assertion.Equal(t, a.Keys(false), []string{"a", "c"}, "keys after delete")
+	assertion.Equal(t, a.Len(), 2, "length after delete")
+
+	a.Set("b", 1)
+	assertion.Equal(t, a.Keys(false), []string{"a", "c", "b"}, "a key added again goes last")
+}
+
+// WordCountProgram counts words like
+// awk '{ for (i = 1; i <= NF; i++) count[$i]++ } END { for (w in count) print w, count[w] }'
+type WordCountProgram struct {
+	command.SimpleProgram
+	sorted bool
+}
+
+func (p WordCountProgram) Action(ctx *command.Context) (string, bool) {
+	count := ctx.ArrayVar("count")
+	for _, word := range ctx.FieldsSlice() {
+		count.Incr(word, 1)
+	}
+	return "", false
+}
+
+func (p WordCountProgram) End(ctx *command.Context) (string, error) {
+	count := ctx.ArrayVar("count")
+	for _, word := range count.Keys(p.sorted) {
+		if err := ctx.EmitFields(word, count.Get(word)); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+func TestAwk_Array_WordCount(t *testing.T) {
+	input := []string{"the cat", "the dog and the cat", "a bird"}
+
+	result := run.Command(command.Awk(WordCountProgram{})).WithStdinLines(input...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"the 3", "cat 2", "dog 1", "and 1", "a 1", "bird 1"})
+
+	result = run.Command(command.Awk(WordCountProgram{sorted: true})).WithStdinLines(input...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 1", "and 1", "bird 1", "cat 2", "dog 1", "the 3"})
+}
+
+func TestArray_Incr_OtherTypes(t *testing.T) {
+	ctx := &command.Context{}
+	a := ctx.ArrayVar("a")
+	a.Set("int32", int32(5))
+	a.Set("bool", true)
+	a.Set("prefix", "42abc")
+
+	assertion.Equal(t, a.Incr("int32", 1), 6.0, "int32")
+	assertion.Equal(t, a.Incr("bool", 1), 2.0, "true counts as 1")
+	assertion.Equal(t, a.Incr("prefix", 1), 43.0, "numeric prefix")
+	assertion.Equal(t, a.Get("prefix"), 43.0, "stored")
+}
+
+func TestArray_Keys_Numeric(t *testing.T) {
+	ctx := &command.Context{}
+	a := ctx.ArrayVar("a")
+	for _, k := range []string{"10", "9", "-1", "2.5"} {
+		a.Set(k, 1)
+	}
+	assertion.Equal(t, a.Keys(true), []string{"-1", "2.5", "9", "10"}, "numeric keys sort numerically")
+
+	a.Set("x", 1)
+	assertion.Equal(t, a.Keys(true), []string{"-1", "10", "2.5", "9", "x"}, "others lexically")
+}
+
+func TestAwk_Array_SharedVariables(t *testing.T) {
+	result := run.Command(command.Awk(WordCountProgram{sorted: true}, command.SharedVariables(true))).
+		WithStdinLines("b a", "a").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 2", "b 1"})
+}
+
+func TestAwk_Array_ScalarVariable(t *testing.T) {
+	result := run.Command(command.Awk(WordCountProgram{}, command.Variable{Name: "count", Value: 1})).
+		WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "record 1: variable count is int, not an Array")
+}
@@ -0,0 +1,67 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestAwk_FromChannel(t *testing.T) {
+	ch := make(chan string)
+	go func() {
+		defer close(ch)
+		for i := range 3 {
+			ch <- fmt.Sprintf("record %d", i)
+		}
+	}()
+
+	prog := &CountingProgram{}
+	result := run.Command(command.Awk(prog, command.FromChannel(ch))).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"Total lines: 3"})
+}
+
+func TestAwk_FromChannel_Fields(t *testing.T) {
+	ch := make(chan string, 2)
+	ch <- "a,b"
+	ch <- "c"
+	close(ch)
+
+	result := run.Command(command.Awk(FieldCountProgram{}, command.FromChannel(ch), command.FieldSeparator(","))).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"2 fields", "1 fields"})
+}
+
+func TestAwk_FromChannel_Cancel(t *testing.T) {
+	ch := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		cmd := command.Awk(LineNumberProgram{}, command.FromChannel(ch))
+		done <- cmd.Executor()(ctx, nil, &stdout, &stderr)
+	}()
+
+	// The channel is unbuffered, so both records were received once sent
+	ch <- "first"
+	ch <- "second"
+	cancel()
+
+	select {
+	case err := <-done:
+		assertion.True(t, errors.Is(err, context.Canceled), "cancellation stops waiting for records")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run did not stop when cancelled")
+	}
+	assertion.True(t, strings.HasPrefix(stdout.String(), "1: first\n"), "records before the cancellation are processed")
+}
@@ -0,0 +1,82 @@
+package command
+
+import "math"
+
+// StatsResult are the statistics of a numeric column computed by ColumnStats
+type StatsResult struct {
+	// Count is the number of numeric values
+	Count int64
+
+	Sum, Min, Max, Mean float64
+
+	// StdDev is the sample standard deviation (0 for fewer than two values)
+	StdDev float64
+}
+
+// columnStats is the Program of ColumnStats
+type columnStats struct {
+	SimpleProgram
+	field int
+}
+
+// columnAccumulator holds the statistics of a run of ColumnStats
+type columnAccumulator struct {
+	result StatsResult
+
+	// m2 is the sum of squared differences from the mean (Welford)
+	m2 float64
+}
+
+// ColumnStats returns a Program computing the count, sum, minimum, maximum,
+// mean and standard deviation of field's numeric values. Values that are not
+// numbers are skipped and counted in Stats.Warnings. End emits a single
+// summary record, numbers formatted with OFMT:
+//
+//	count=3 sum=6 min=1 max=3 mean=2 stddev=1
+//
+// The numbers are also stored in Stats.Column for StatsRecipient.
+func ColumnStats(field int) Program {
+	return &columnStats{field: field}
+}
+
+// accumulator returns the statistics of the current run
+func (s *columnStats) accumulator(ctx *Context) *columnAccumulator {
+	return ctx.state(s, func() any { return new(columnAccumulator) }).(*columnAccumulator)
+}
+
+func (s *columnStats) Action(ctx *Context) (string, bool) {
+	value, ok := number(ctx.Field(s.field))
+	if !ok {
+		ctx.warn()
+		return "", false
+	}
+	s.accumulator(ctx).add(value)
+	return "", false
+}
+
+// add accumulates value, updating the mean and variance with Welford's
+// algorithm, which stays accurate for large values and long inputs
+func (s *columnAccumulator) add(value float64) {
+	r := &s.result
+	r.Count++
+	if r.Count == 1 {
+		r.Min, r.Max = value, value
+	}
+	r.Sum += value
+	r.Min, r.Max = math.Min(r.Min, value), math.Max(r.Max, value)
+	delta := value - r.Mean
+	r.Mean += delta / float64(r.Count)
+	s.m2 += delta * (value - r.Mean)
+	if r.Count > 1 {
+		r.StdDev = math.Sqrt(s.m2 / float64(r.Count-1))
+	}
+}
+
+func (s *columnStats) End(ctx *Context) (string, error) {
+	r := s.accumulator(ctx).result
+	if ctx.stats != nil {
+		ctx.stats.Column = &r
+	}
+	return ctx.Print("count="+ctx.format(r.Count), "sum="+ctx.format(r.Sum), "min="+ctx.format(r.Min),
+		"max="+ctx.format(r.Max), "mean="+ctx.format(r.Mean), "stddev="+ctx.format(r.StdDev)), nil
+}
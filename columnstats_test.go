@@ -0,0 +1,69 @@
+package command_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestColumnStats(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(command.ColumnStats(2), command.StatsRecipient(&stats))).
+		WithStdinLines("a 2", "b 4", "c n/a", "d 4", "e 4", "f 5", "g 5", "h 7", "i 9").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"count=8 sum=40 min=2 max=9 mean=5 stddev=2.13809"})
+	assertion.Equal(t, stats.Warnings, int64(1), "the non-numeric value is counted")
+	assertion.Equal(t, stats.Column.Count, int64(8), "count")
+	assertion.Equal(t, stats.Column.Sum, 40.0, "sum")
+	assertion.Equal(t, stats.Column.Mean, 5.0, "mean")
+	assertion.True(t, math.Abs(stats.Column.StdDev-math.Sqrt(32.0/7)) < 1e-12, "sample standard deviation")
+}
+
+func TestColumnStats_NotNumbers(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(command.ColumnStats(1), command.StatsRecipient(&stats))).
+		WithStdinLines("1", "nan", "Inf", "-infinity", "0x10", "3kg", " 2 ").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"count=2 sum=3 min=1 max=2 mean=1.5 stddev=0.707107"})
+	assertion.Equal(t, stats.Warnings, int64(5), "nan, inf, hexadecimal and partial numbers are skipped")
+}
+
+func TestColumnStats_OFMT(t *testing.T) {
+	output := execute(t, command.ColumnStats(-1), "1 -1.5\n2 3\n", command.OutputFormat("%.2f"))
+	assertion.Equal(t, output, "count=2 sum=1.50 min=-1.50 max=3 mean=0.75 stddev=3.18\n", "numbers use OFMT")
+}
+
+func TestColumnStats_Empty(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(command.ColumnStats(1), command.StatsRecipient(&stats))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"count=0 sum=0 min=0 max=0 mean=0 stddev=0"})
+	assertion.Equal(t, stats.Column.Count, int64(0), "no values")
+}
+
+func TestColumnStats_HugeValues(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(command.ColumnStats(1), command.StatsRecipient(&stats))).
+		WithStdinLines("1e308", "1e308", "1e308").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.True(t, math.IsInf(stats.Column.Sum, 1), "the sum overflows")
+	assertion.Equal(t, stats.Column.Mean, 1e308, "the mean does not")
+	assertion.Equal(t, stats.Column.StdDev, 0.0, "nor does the deviation")
+}
+
+func TestColumnStats_Precision(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(command.ColumnStats(1), command.StatsRecipient(&stats))).
+		WithStdinLines("1000000004", "1000000007", "1000000013", "1000000016").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, stats.Column.Mean, 1000000010.0, "mean")
+	assertion.True(t, math.Abs(stats.Column.StdDev-math.Sqrt(30)) < 1e-9, "no cancellation around a large mean")
+}
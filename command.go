@@ -2,12 +2,23 @@ package command
 
 import (
 	"bufio"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
+	"math"
+	"os"
+	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	gloo "github.com/gloo-foo/framework"
+	"github.com/yupsh/awk/internal/text"
 )
 
 // Context provides access to awk's execution context for each line
@@ -15,37 +26,207 @@ type Context struct {
 	// Fields contains the split fields from the current line
 	// Fields[0] is $0 (the whole line)
 	// Fields[1] is $1 (first field), etc.
+	//
+	// Reading Fields directly is deprecated: use Field, FieldsSlice and LastField,
+	// which neither include $0 by surprise nor alias memory the engine may reuse.
 	Fields []string
 
 	// NR is the current record (line) number (1-based)
 	NR int64
 
-	// NF is the number of fields in the current record
+	// FNR is the number of the current record in the current input, like
+	// awk's FNR: it starts again at 1 with every file while NR goes on
+	FNR int64
+
+	// Filename is the name of the current input, like awk's FILENAME: the
+	// file operand or Source name, "-" for stdin given as "-", and "" for
+	// stdin otherwise
+	Filename string
+
+	// NF is the number of fields in the current record; change it with SetNF
 	NF int
 
-	// FS is the input field separator
+	// FS is the input field separator. Programs may change it in Begin or
+	// Action; as in awk, a change applies from the next record on.
 	FS string
 
-	// OFS is the output field separator (used when printing multiple fields)
+	// OFS is the output field separator (used when printing multiple fields).
+	// Programs may change it at any time.
 	OFS string
 
-	// Variables allows access to user-defined variables
+	// Variables allows access to user-defined variables.
+	// With SharedVariables, use Var/SetVar/AddVar rather than the map directly.
 	Variables map[string]any
 
-	// RS is the record separator (usually newline)
+	// OFMT is the printf format used by Print for non-integral numbers (default "%.6g")
+	OFMT string
+
+	// BytesMode makes the string helpers (Length, Substr, ...) count bytes
+	// instead of characters
+	BytesMode bool
+
+	// RS is the record separator (usually newline).
+	// A single character is literal; a longer RS is a regular expression.
+	// Programs may change it in Begin; a later change applies from the next
+	// input on.
 	RS string
+
+	// ORS is the output record separator ending every record output (default
+	// "\n"). Programs may change it at any time.
+	ORS string
+
+	// RT is the text that terminated the current record ("" for a final
+	// record without terminator), so Field(0)+RT reproduces the input
+	RT string
+
+	// RStart and RLength are the position and length of the match found by
+	// the last call to Match, like awk's RSTART and RLENGTH
+	RStart  int
+	RLength int
+
+	// Pass is the current pass over the input (1-based) of a MultiPass Program
+	Pass int
+
+	// ctx is the context.Context of the current run
+	ctx context.Context
+
+	// output writes a record and its terminator to the output of the run
+	output func(record, terminator string) error
+
+	// emitTo writes a record and its terminator to a named output of the run
+	emitTo func(name, record, terminator string) error
+
+	// abort is the error the Program aborted the run with
+	abort error
+
+	// next is set by Next to skip the remaining rules of a Rules chain
+	next bool
+
+	// exit is set by Exit to stop reading the inputs
+	exit *ExitError
+
+	// stderr receives the messages of Warnf and Errorf, prefixed with the
+	// stage of a Pipe; errs are the errors of Errorf, kept with errorfFails
+	stderr      io.Writer
+	stage       string
+	errs        []error
+	errorfFails bool
+
+	// states are the run state of the ready-made Programs, by Program
+	states map[any]any
+
+	// getline reads the next record of the input for Getline (nil when
+	// there is none to read)
+	getline func() (string, bool)
+
+	// stats are the counters of the run, which started at started
+	stats   *Stats
+	started time.Time
+
+	// mu guards Variables when they are shared between goroutines (nil otherwise)
+	mu *sync.Mutex
+
+	// readers are the files opened with Open, by name, and baseDir the
+	// directory relative names are resolved against
+	readers map[string]*LineReader
+	baseDir string
+
+	// regexps are the patterns compiled by Regexp, up to regexpCacheSize
+	regexps         *regexpCache
+	regexpCacheSize int
+
+	// lookback keeps the records for Prev, with Lookback
+	lookback *lookback
+
+	// environ holds the environment visible to the program (awk's ENVIRON)
+	environ map[string]string
 }
 
-// Field returns the field at the given index (0 = whole line, 1 = first field, etc.)
+// Field returns the field at the given index (0 = whole line, 1 = first field, etc.).
+// Negative indexes count from the end: -1 is the last field ($NF), -2 the one before it.
 func (c *Context) Field(index int) string {
+	index = fieldIndex(len(c.Fields), index)
 	if index < 0 || index >= len(c.Fields) {
 		return ""
 	}
 	return c.Fields[index]
 }
 
-// SetField sets the value of a field
+// FieldsSlice returns a copy of fields 1..NF, without $0.
+// The copy is safe to retain after the current record.
+func (c *Context) FieldsSlice() []string {
+	if len(c.Fields) <= 1 {
+		return []string{}
+	}
+	return slices.Clone(c.Fields[1:])
+}
+
+// LastField returns the last field ($NF), or "" when the record has no fields
+func (c *Context) LastField() string {
+	return c.Field(-1)
+}
+
+// FieldFloat returns the field at index as a number, like awk's $i + 0: the
+// numeric prefix of the field counts ("3.5kg" is 3.5, "0x1A" is 0) and a
+// field without one is 0. ok reports whether the whole field is a number,
+// surrounding blanks aside; it is false for missing fields.
+func (c *Context) FieldFloat(index int) (value float64, ok bool) {
+	prefix, whole := text.NumericPrefix(c.Field(index))
+	if prefix == "" {
+		return 0, false
+	}
+	value, _ = strconv.ParseFloat(prefix, 64) // out of range is ±Inf
+	return value, whole
+}
+
+// FieldInt is FieldFloat truncated toward zero like awk's int(). Integers are
+// read exactly, beyond the precision of a float64; values beyond the range of
+// an int64 are clamped to it.
+func (c *Context) FieldInt(index int) (value int64, ok bool) {
+	prefix, whole := text.NumericPrefix(c.Field(index))
+	if prefix == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseInt(prefix, 10, 64); err == nil {
+		return n, whole
+	}
+	f, _ := strconv.ParseFloat(prefix, 64)
+	switch {
+	case f >= math.MaxInt64:
+		return math.MaxInt64, whole
+	case f <= math.MinInt64:
+		return math.MinInt64, whole
+	}
+	return int64(f), whole
+}
+
+// Join returns fields from..to joined with OFS, like awk's loop printing
+// $3 onward. Negative indexes count from the end as in Field, and to = 0 is
+// NF; to is capped at NF. An empty or reversed range, or one starting at $0
+// or past NF, returns "".
+func (c *Context) Join(from, to int) string {
+	n := len(c.Fields)
+	if to == 0 {
+		to = n - 1
+	}
+	from, to = fieldIndex(n, from), min(fieldIndex(n, to), n-1)
+	if from < 1 || to < from {
+		return ""
+	}
+	return strings.Join(c.Fields[from:to+1], c.OFS)
+}
+
+// JoinAll returns all the fields joined with OFS. Unlike Field(0) it is
+// rebuilt with OFS, so it differs from $0 when FS and OFS do.
+func (c *Context) JoinAll() string {
+	return c.Join(1, 0)
+}
+
+// SetField sets the value of a field; negative indexes count from the end as in Field.
+// Like awk, setting a field past NF adds empty fields up to it, and setting
+// any field but $0 rebuilds $0 from the fields joined with OFS.
 func (c *Context) SetField(index int, value string) {
+	index = fieldIndex(len(c.Fields), index)
 	if index < 0 {
 		return
 	}
@@ -55,33 +236,447 @@ func (c *Context) SetField(index int, value string) {
 	}
 	c.Fields[index] = value
 	c.NF = len(c.Fields) - 1 // Don't count $0
+	if index > 0 {
+		c.Fields[0] = strings.Join(c.Fields[1:], c.OFS)
+	}
+}
+
+// SetNF sets the number of fields, like assigning NF in awk: fields past n
+// are dropped, missing ones are added empty, and $0 is rebuilt from the
+// fields joined with OFS (SetNF(0) leaves it empty). A negative n is ignored.
+func (c *Context) SetNF(n int) {
+	if n < 0 {
+		return
+	}
+	if len(c.Fields) == 0 {
+		c.Fields = []string{""}
+	}
+	if n < len(c.Fields)-1 {
+		c.Fields = c.Fields[:n+1]
+	}
+	for len(c.Fields) <= n {
+		c.Fields = append(c.Fields, "")
+	}
+	c.NF = n
+	c.Fields[0] = strings.Join(c.Fields[1:], c.OFS)
+}
+
+// fieldIndex resolves a negative index relative to the last of n fields
+// (counting $0). Indexes below -NF resolve to -1, which matches no field.
+func fieldIndex(n, index int) int {
+	if index >= 0 {
+		return index
+	}
+	index += n
+	if index < 1 {
+		return -1
+	}
+	return index
 }
 
 // Var returns a variable value
 func (c *Context) Var(name string) any {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
 	if c.Variables == nil {
 		return nil
 	}
 	return c.Variables[name]
 }
 
+// VarFloat returns a variable as a number, converted like awk does: unset
+// variables are 0, strings count by their numeric prefix ("42abc" is 42) and
+// booleans are 1 or 0
+func (c *Context) VarFloat(name string) float64 {
+	return awkNumber(c.Var(name))
+}
+
+// VarInt returns a variable as a number like VarFloat, truncated toward zero
+// like awk's int() and clamped to the range of an int64
+func (c *Context) VarInt(name string) int64 {
+	return awkInt(c.Var(name))
+}
+
+// VarString returns a variable as a string: unset variables are "", booleans
+// "1" or "0", and numbers are formatted like Print, through OFMT unless
+// integral
+func (c *Context) VarString(name string) string {
+	switch v := c.Var(name).(type) {
+	case nil:
+		return ""
+	case bool:
+		if v {
+			return "1"
+		}
+		return "0"
+	default:
+		return c.format(v)
+	}
+}
+
 // SetVar sets a variable value
 func (c *Context) SetVar(name string, value any) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
 	if c.Variables == nil {
 		c.Variables = make(map[string]any)
 	}
 	c.Variables[name] = value
 }
 
-// Print formats and returns a string with fields separated by OFS
+// DeleteVar removes a variable
+func (c *Context) DeleteVar(name string) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	delete(c.Variables, name)
+}
+
+// ClearVars removes all variables
+func (c *Context) ClearVars() {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	clear(c.Variables)
+}
+
+// VarNames returns the names of all variables in sorted order
+func (c *Context) VarNames() []string {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	return slices.Sorted(maps.Keys(c.Variables))
+}
+
+// AddVar adds delta to a numeric variable and returns the new value.
+// Unset variables start at 0, and int variables stay int when delta is integral.
+// Other values count as VarFloat reads them.
+// With SharedVariables the update is atomic, so concurrent goroutines can accumulate into it.
+func (c *Context) AddVar(name string, delta float64) float64 {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if c.Variables == nil {
+		c.Variables = make(map[string]any)
+	}
+	sum := add(c.Variables[name], delta)
+	c.Variables[name] = sum
+	switch v := sum.(type) {
+	case int:
+		return float64(v)
+	case int64:
+		return float64(v)
+	default:
+		return v.(float64)
+	}
+}
+
+// add returns current + delta, keeping integer types when possible. Other
+// values are converted like VarFloat, so "42abc" counts as 42 and true as 1.
+func add(current any, delta float64) any {
+	integral := delta == math.Trunc(delta)
+	switch v := current.(type) {
+	case nil:
+		if integral {
+			return int(delta)
+		}
+		return delta
+	case int:
+		if integral {
+			return v + int(delta)
+		}
+		return float64(v) + delta
+	case int64:
+		if integral {
+			return v + int64(delta)
+		}
+		return float64(v) + delta
+	default:
+		return awkNumber(v) + delta
+	}
+}
+
+// Context returns the context.Context of the current run, valid in Begin,
+// Condition, Action and End. Long-running Programs should honor its cancellation.
+func (c *Context) Context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+	return c.ctx
+}
+
+// Environ returns the value of an environment variable, or "" if it is unset
+func (c *Context) Environ(name string) string {
+	return c.environ[name]
+}
+
+// EnvironMap returns a copy of the environment visible to the program
+func (c *Context) EnvironMap() map[string]string {
+	env := make(map[string]string, len(c.environ))
+	maps.Copy(env, c.environ)
+	return env
+}
+
+// state returns the state a ready-made Program keeps for the current run
+// under key, created with create on first use. Keeping it out of the Program
+// lets one Program value run on several goroutines at once.
+func (c *Context) state(key any, create func() any) any {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	s, ok := c.states[key]
+	if !ok {
+		if c.states == nil {
+			c.states = make(map[any]any)
+		}
+		s = create()
+		c.states[key] = s
+	}
+	return s
+}
+
+// split sets $0 to line and splits it into fields using FS
+func (c *Context) split(line string) {
+	c.Fields = make([]string, 0, 16)
+	c.Fields = append(c.Fields, line) // $0
+
+	var fields []string
+	if c.FS == " " {
+		// Default: split on whitespace
+		fields = strings.Fields(line)
+	} else if c.FS == "" {
+		// Every character is a field, or every byte in BytesMode
+		if c.BytesMode {
+			fields = text.Bytes(line)
+		} else {
+			fields = text.Chars(line)
+		}
+	} else {
+		// Custom separator
+		if line == "" {
+			// Empty line has no fields, regardless of separator
+			fields = []string{}
+		} else {
+			fields = strings.Split(line, c.FS)
+		}
+	}
+	c.Fields = append(c.Fields, fields...)
+	c.NF = len(fields)
+}
+
+// setRecord sets the fields of a record; nil fields are split from line, and
+// an empty line is the fields joined with OFS
+func (c *Context) setRecord(fields []string, line string) {
+	if fields == nil {
+		c.split(line)
+		return
+	}
+	if line == "" {
+		line = strings.Join(fields, c.OFS)
+	}
+	c.Fields = append(make([]string, 0, len(fields)+1), line)
+	c.Fields = append(c.Fields, fields...)
+	c.NF = len(fields)
+}
+
+// Print formats and returns a string with fields separated by OFS.
+// Numbers print the same whatever their Go type: integers and integral
+// floats within the int64 range without a decimal point or exponent, other
+// floats through OFMT.
 func (c *Context) Print(values ...any) string {
 	parts := make([]string, len(values))
 	for i, v := range values {
-		parts[i] = fmt.Sprint(v)
+		parts[i] = c.format(v)
 	}
 	return strings.Join(parts, c.OFS)
 }
 
+// format renders a single value for output. Values of numeric kinds,
+// including named types such as type Celsius float64, are rendered as
+// numbers unless they are a fmt.Stringer.
+func (c *Context) format(v any) string {
+	switch n := v.(type) {
+	case string:
+		return n
+	case int:
+		return strconv.Itoa(n)
+	case int64:
+		return strconv.FormatInt(n, 10)
+	case float64:
+		return c.formatFloat(n)
+	case float32:
+		return c.formatFloat(float64(n))
+	case fmt.Stringer, error:
+		return fmt.Sprint(v)
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(rv.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(rv.Uint(), 10)
+	case reflect.Float32, reflect.Float64:
+		return c.formatFloat(rv.Float())
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// formatFloat renders integral values within int64 range as integers and
+// everything else with OFMT
+func (c *Context) formatFloat(f float64) string {
+	if f == math.Trunc(f) && f >= math.MinInt64 && f < math.MaxInt64 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	ofmt := c.OFMT
+	if ofmt == "" {
+		ofmt = defaultOFMT
+	}
+	return fmt.Sprintf(ofmt, f)
+}
+
+// Abort fails the run with err once the Program returns from the current
+// method: the output of the current record is dropped, no further records are
+// read and End is not called (when aborting from End, its output is dropped).
+// The run returns err wrapped with the record number and input name. Only the
+// first Abort counts; a nil err aborts with ErrAborted.
+func (c *Context) Abort(err error) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if c.abort == nil {
+		c.abort = cmp.Or(err, ErrAborted)
+	}
+}
+
+// ErrAborted is the error of a run aborted with a nil error
+var ErrAborted = errors.New("aborted")
+
+// aborted returns the error the run was aborted with, if any
+func (c *Context) aborted() error {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	return c.abort
+}
+
+// EmitFields writes values formatted like Print, terminated with ORS, to the
+// output right away, so an Action or End can output several records. It
+// returns the error writing them, if any.
+func (c *Context) EmitFields(values ...any) error {
+	return c.emitRecord(c.Print(values...))
+}
+
+// emitRecord writes record, terminated with ORS, to the output of the run
+func (c *Context) emitRecord(record string) error {
+	if c.output == nil {
+		return errNoOutput
+	}
+	return c.output(record, c.ORS)
+}
+
+// errNoOutput is returned when emitting from a Context outside of a run
+var errNoOutput = errors.New("awk: context has no output outside of a run")
+
+// Length returns the number of characters in s, or bytes in BytesMode
+func (c *Context) Length(s string) int {
+	if c.BytesMode {
+		return text.LengthBytes(s)
+	}
+	return text.Length(s)
+}
+
+// Substr returns at most length characters of s starting at the 1-based
+// position start, clamped like awk's substr(). In BytesMode it counts bytes.
+func (c *Context) Substr(s string, start, length int) string {
+	if c.BytesMode {
+		return text.SubstrBytes(s, start, length)
+	}
+	return text.Substr(s, start, length)
+}
+
+// Index returns the 1-based position of the first t in s, or 0 if s does not
+// contain t, like awk's index(). In BytesMode it counts bytes.
+func (c *Context) Index(s, t string) int {
+	if c.BytesMode {
+		return text.IndexBytes(s, t)
+	}
+	return text.Index(s, t)
+}
+
+// Match returns the 1-based position and the length of the leftmost match of
+// the regular expression pattern in s, or 0 and -1 if there is none, like
+// awk's match(), and stores them in RStart and RLength. Positions and lengths
+// count characters (runes), so they suit Substr; in BytesMode they count
+// bytes. Patterns use the syntax of Regexp and are compiled once per run. As
+// in awk, the match is the leftmost-longest one: of two alternatives matching
+// at the same position the longer wins. An invalid pattern aborts the run.
+func (c *Context) Match(s, pattern string) (start, length int) {
+	c.RStart, c.RLength = 0, -1
+	re, err := c.compile(regexpKey{pattern: pattern, longest: true})
+	if err != nil {
+		c.Abort(err)
+		return c.RStart, c.RLength
+	}
+	loc := re.FindStringIndex(s)
+	switch {
+	case loc == nil:
+	case c.BytesMode:
+		c.RStart, c.RLength = loc[0]+1, loc[1]-loc[0]
+	default:
+		c.RStart, c.RLength = text.Length(s[:loc[0]])+1, text.Length(s[loc[0]:loc[1]])
+	}
+	return c.RStart, c.RLength
+}
+
+// Split splits s like awk's split(): sep " " splits on runs of whitespace and
+// ignores leading and trailing whitespace, a single character is a literal
+// separator, a longer sep is a regular expression (see Regexp), and an empty
+// sep splits s into characters, or bytes in BytesMode. An empty s has no
+// elements. An invalid regular expression aborts the run.
+func (c *Context) Split(s, sep string) []string {
+	switch {
+	case s == "":
+		return []string{}
+	case sep == " ":
+		return strings.Fields(s)
+	case sep == "" && c.BytesMode:
+		return text.Bytes(s)
+	case sep == "":
+		return text.Chars(s)
+	case len(sep) == 1:
+		return strings.Split(s, sep)
+	}
+	re, err := c.Regexp(sep)
+	if err != nil {
+		c.Abort(err)
+		return []string{}
+	}
+	return re.Split(s, -1)
+}
+
+// SplitInto stores the elements of Split(s, sep) in m under the keys 1 to n,
+// after deleting its other entries, and returns n, like awk's
+// n = split(s, m, sep)
+func (c *Context) SplitInto(s, sep string, m map[int]string) int {
+	clear(m)
+	elements := c.Split(s, sep)
+	for i, element := range elements {
+		m[i+1] = element
+	}
+	return len(elements)
+}
+
 // Program defines the interface for awk-style programs
 // All methods are optional - implement only what you need
 type Program interface {
@@ -102,106 +697,168 @@ type Program interface {
 	End(ctx *Context) (output string, err error)
 }
 
+// Resetter is implemented by Programs whose own state should be cleared
+// between input files when the ResetPerFile option is set
+type Resetter interface {
+	Reset()
+}
+
 // SimpleProgram provides default implementations for all Program methods
 // Embed this in your program struct and override only what you need
 type SimpleProgram struct{}
 
-func (SimpleProgram) Begin(ctx *Context) error              { return nil }
-func (SimpleProgram) Condition(ctx *Context) bool           { return true }
-func (SimpleProgram) Action(ctx *Context) (string, bool)    { return ctx.Field(0), true }
-func (SimpleProgram) End(ctx *Context) (string, error)      { return "", nil }
+func (SimpleProgram) Begin(ctx *Context) error           { return nil }
+func (SimpleProgram) Condition(ctx *Context) bool        { return true }
+func (SimpleProgram) Action(ctx *Context) (string, bool) { return ctx.Field(0), true }
+func (SimpleProgram) End(ctx *Context) (string, error)   { return "", nil }
 
 type command struct {
 	program Program
 	inputs  gloo.Inputs[gloo.File, flags]
+
+	// err lists the invalid parameters; the Executor fails with it
+	err error
 }
 
+// Awk returns a command running program over its inputs. If a parameter is
+// unknown or invalid, the command's Executor fails with an error listing them
+// all before reading any input; use AwkE to get that error up front.
+//
+// The command is not changed by running it: every Execute keeps its run state
+// (NR, variables, outputs, open files) to itself, so one command may run from
+// several goroutines at once, with their own stdin and stdout, as long as the
+// Program and the values given as parameters (a StatsRecipient, Sources, named
+// outputs) can be shared. The package's ready-made Programs (GroupBy,
+// ColumnStats, SelectColumns, Template, ...) keep their state per run and can.
 func Awk(program Program, parameters ...any) gloo.Command {
+	if s, ok := program.(stage); ok {
+		program, parameters = s.Program, append(slices.Clone(parameters), s.parameters...)
+	}
 	cmd := command{
 		program: program,
-		inputs:  gloo.Initialize[gloo.File, flags](parameters...),
-	}
-	if cmd.inputs.Flags.FieldSeparator == "" {
-		cmd.inputs.Flags.FieldSeparator = " "
-	}
-	if cmd.inputs.Flags.OutputFieldSeparator == "" {
-		cmd.inputs.Flags.OutputFieldSeparator = " "
+		inputs:  initialize(parameters...),
+		err:     validate(parameters),
 	}
 	return cmd
 }
 
+// AwkE is Awk returning the error for unknown or invalid parameters
+func AwkE(program Program, parameters ...any) (gloo.Command, error) {
+	cmd := Awk(program, parameters...)
+	return cmd, cmd.(command).err
+}
+
 func (c command) Executor() gloo.CommandExecutor {
-	return c.inputs.Wrap(func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) error {
-		// Initialize context
-		awkCtx := &Context{
-			NR:        0,
-			FS:        string(c.inputs.Flags.FieldSeparator),
-			OFS:       string(c.inputs.Flags.OutputFieldSeparator),
-			RS:        "\n",
-			Variables: make(map[string]any),
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+		if c.err != nil {
+			unsink(c.inputs.Flags)
+			return c.err
 		}
+		stdout, flush := buffer(stdout, c.inputs.Flags.WriteBufferSize)
+		defer func() {
+			if flushErr := flush(); err == nil {
+				err = flushErr
+			}
+		}()
+		stdout, closeEncoder := encode(stdout, c.inputs.Flags)
+		defer func() {
+			if closeErr := closeEncoder(); err == nil {
+				err = closeErr
+			}
+		}()
+
+		emit, out, done := sink(ctx, c.inputs.Flags, stdout)
+		defer func() {
+			if doneErr := done(); err == nil {
+				err = doneErr
+			}
+		}()
 
-		// Copy initial variables from flags
-		for k, v := range c.inputs.Flags.Variables {
-			awkCtx.Variables[k] = v
+		e := newEngine(ctx, c.program, c.inputs.Flags, emit, stderr, "")
+		e.out = out
+		if passthrough(c.program) && out == stdout {
+			e.copyTo = stdout
 		}
+		defer func() { e.finish(err) }()
 
-		// Call Begin
-		if err := c.program.Begin(awkCtx); err != nil {
-			return fmt.Errorf("BEGIN: %w", err)
+		if err := e.begin(); err != nil {
+			return err
+		}
+		if err := e.scanInputs(c.inputs, stdin); err != nil {
+			return err
 		}
+		return e.end()
+	}
+}
 
-		// Process lines
-		scanner := bufio.NewScanner(stdin)
-		for scanner.Scan() {
-			awkCtx.NR++
-			line := scanner.Text()
+// initialize parses the parameters of a command. File operands are opened by
+// the engine, one at a time, on every run, so the handles the framework opened
+// while parsing are released right away.
+func initialize(parameters ...any) gloo.Inputs[gloo.File, flags] {
+	inputs := gloo.Initialize[gloo.File, flags](parameters...)
+	if len(inputs.Positional) > 0 {
+		_ = inputs.Close()
+	}
+	inputs.Flags = defaults(inputs.Flags)
+	return inputs
+}
 
-		// Split into fields
-		awkCtx.Fields = make([]string, 0, 16)
-		awkCtx.Fields = append(awkCtx.Fields, line) // $0
+const (
+	// defaultOFMT is awk's default output format for numbers
+	defaultOFMT = "%.6g"
 
-		var fields []string
-		if awkCtx.FS == " " {
-			// Default: split on whitespace
-			fields = strings.Fields(line)
-		} else {
-			// Custom separator
-			if line == "" {
-				// Empty line has no fields, regardless of separator
-				fields = []string{}
-			} else {
-				fields = strings.Split(line, awkCtx.FS)
-			}
-		}
-		awkCtx.Fields = append(awkCtx.Fields, fields...)
-		awkCtx.NF = len(fields)
+	// defaultReadBufferSize is the initial size of the record buffer
+	defaultReadBufferSize = 4096
+)
 
-			// Check condition
-			if !c.program.Condition(awkCtx) {
-				continue
-			}
+// defaults fills in the separators left unset by the parameters
+func defaults(f flags) flags {
+	if f.FieldSeparator == "" {
+		f.FieldSeparator = " "
+	}
+	if f.OutputFieldSeparator == "" {
+		f.OutputFieldSeparator = " "
+	}
+	if f.OutputFormat == "" {
+		f.OutputFormat = defaultOFMT
+	}
+	if f.RecordSeparator == "" {
+		f.RecordSeparator = "\n"
+	}
+	if f.OutputRecordSeparator == "" {
+		f.OutputRecordSeparator = "\n"
+	}
+	if f.MaxRecordSize == 0 {
+		f.MaxRecordSize = bufio.MaxScanTokenSize
+	}
+	return f
+}
 
-			// Execute action
-			output, emit := c.program.Action(awkCtx)
-			if emit {
-				fmt.Fprintln(stdout, output)
+// configure applies the flag parameters of every group in order, so later
+// groups override earlier ones
+func configure(groups ...[]any) flags {
+	var f flags
+	for _, parameters := range groups {
+		for _, parameter := range parameters {
+			if s, ok := parameter.(gloo.Switch[flags]); ok {
+				s.Configure(&f)
 			}
 		}
+	}
+	return defaults(f)
+}
 
-		if err := scanner.Err(); err != nil {
-			return err
-		}
-
-		// Call End
-		endOutput, err := c.program.End(awkCtx)
-		if err != nil {
-			return fmt.Errorf("END: %w", err)
-		}
-		if endOutput != "" {
-			fmt.Fprintln(stdout, endOutput)
+// environment returns the environment for a run: the injected one if the
+// Environment flag was given, the process environment otherwise
+func environment(f flags) map[string]string {
+	if f.Environment != nil {
+		return maps.Clone(map[string]string(f.Environment))
+	}
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if name, value, ok := strings.Cut(entry, "="); ok {
+			env[name] = value
 		}
-
-		return nil
-	})
+	}
+	return env
 }
@@ -1,11 +1,18 @@
 package command_test
 
 import (
+	"bytes"
+	"context"
 	"errors"
 	"fmt"
+	"math"
+	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/gloo-foo/testable/assertion"
 	"github.com/gloo-foo/testable/run"
@@ -30,7 +37,10 @@ func TestContext_Field(t *testing.T) {
 		{"field 1", 1, "first"},
 		{"field 2", 2, "second"},
 		{"field 3", 3, "third"},
-		{"negative index", -1, ""},
+		{"last field", -1, "third"},
+		{"second to last field", -2, "second"},
+		{"first field from the end", -3, "first"},
+		{"negative beyond NF", -4, ""},
 		{"out of bounds", 10, ""},
 	}
 
@@ -57,12 +67,204 @@ func TestContext_SetField(t *testing.T) {
 	assertion.Equal(t, ctx.Field(5), "new", "field 5")
 	assertion.Equal(t, ctx.NF, 5, "NF should be 5")
 
-	// Negative index (should be ignored)
+	// Negative index targets fields from the end
+	ctx.SetField(-1, "last")
+	assertion.Equal(t, ctx.Field(5), "last", "field 5 set via -1")
+	ctx.SetField(-4, "second")
+	assertion.Equal(t, ctx.Field(2), "second", "field 2 set via -4")
+
+	// Negative index beyond NF (should be ignored)
 	originalLen := len(ctx.Fields)
-	ctx.SetField(-1, "ignored")
+	ctx.SetField(-6, "ignored")
 	assertion.Equal(t, len(ctx.Fields), originalLen, "fields length unchanged")
 }
 
+func TestContext_SetField_RebuildsRecord(t *testing.T) {
+	ctx := &command.Context{
+		Fields: []string{"f1 f2", "f1", "f2"},
+		NF:     2,
+		OFS:    "|",
+	}
+
+	ctx.SetField(3, "new")
+	assertion.Equal(t, ctx.Field(0), "f1|f2|new", "$0 rebuilt with OFS")
+
+	ctx.SetField(5, "far")
+	assertion.Equal(t, ctx.Field(0), "f1|f2|new||far", "empty fields in between")
+
+	ctx.SetField(0, "whole")
+	assertion.Equal(t, ctx.Field(0), "whole", "$0 set as given")
+	assertion.Equal(t, ctx.NF, 5, "fields kept")
+}
+
+func TestContext_SetNF(t *testing.T) {
+	ctx := &command.Context{
+		Fields: []string{"a b c d", "a", "b", "c", "d"},
+		NF:     4,
+		OFS:    ",",
+	}
+
+	ctx.SetNF(2)
+	assertion.Equal(t, ctx.Field(0), "a,b", "truncated")
+	assertion.Equal(t, ctx.NF, 2, "NF")
+	assertion.Equal(t, ctx.Field(3), "", "dropped field")
+
+	ctx.SetNF(4)
+	assertion.Equal(t, ctx.Field(0), "a,b,,", "extended with empty fields")
+	assertion.Equal(t, ctx.Field(-1), "", "last field is empty")
+
+	ctx.SetNF(-1)
+	assertion.Equal(t, ctx.NF, 4, "negative NF is ignored")
+
+	ctx.SetNF(0)
+	assertion.Equal(t, ctx.Field(0), "", "no fields, empty record")
+	assertion.Equal(t, ctx.NF, 0, "NF")
+	assertion.Equal(t, ctx.FieldsSlice(), []string{}, "no fields")
+
+	empty := &command.Context{}
+	empty.SetNF(2)
+	assertion.Equal(t, empty.FieldsSlice(), []string{"", ""}, "fields of an empty Context")
+}
+
+// RelabelProgram replaces $2 and prints $0
+type RelabelProgram struct {
+	command.SimpleProgram
+}
+
+func (p RelabelProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.SetField(2, "X")
+	return ctx.Field(0), true
+}
+
+func TestAwk_SetField_RebuildsRecord(t *testing.T) {
+	result := run.Command(command.Awk(RelabelProgram{}, command.FieldSeparator(":"), command.OutputFieldSeparator("-"))).
+		WithStdinLines("a:b:c", "d").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a-X-c", "d-X"})
+}
+
+func TestContext_FieldsSlice(t *testing.T) {
+	ctx := &command.Context{
+		Fields: []string{"a b c", "a", "b", "c"},
+	}
+
+	fields := ctx.FieldsSlice()
+	assertion.Equal(t, fields, []string{"a", "b", "c"}, "fields without $0")
+
+	// The result does not alias the context's fields
+	fields[0] = "changed"
+	assertion.Equal(t, ctx.Field(1), "a", "field 1 unchanged")
+
+	empty := &command.Context{Fields: []string{""}}
+	assertion.Equal(t, empty.FieldsSlice(), []string{}, "no fields")
+	assertion.Equal(t, (&command.Context{}).FieldsSlice(), []string{}, "no $0 either")
+}
+
+func TestContext_LastField(t *testing.T) {
+	ctx := &command.Context{
+		Fields: []string{"a b c", "a", "b", "c"},
+	}
+	assertion.Equal(t, ctx.LastField(), "c", "last field")
+
+	empty := &command.Context{Fields: []string{""}}
+	assertion.Equal(t, empty.LastField(), "", "no fields")
+}
+
+func TestContext_FieldFloat_FieldInt(t *testing.T) {
+	ctx := &command.Context{
+		Fields: []string{"", "42", " -3.7 ", "3.5kg", "0x1A", "", "abc", "1e3", "9007199254740993", "1e30"},
+	}
+
+	tests := []struct {
+		name   string
+		index  int
+		float  float64
+		int    int64
+		number bool
+	}{
+		{"integer", 1, 42, 42, true},
+		{"float with blanks", 2, -3.7, -3, true},
+		{"numeric prefix", 3, 3.5, 3, false},
+		{"hex-looking", 4, 0, 0, false},
+		{"empty", 5, 0, 0, false},
+		{"not a number", 6, 0, 0, false},
+		{"exponent", 7, 1000, 1000, true},
+		{"beyond float precision", 8, 9007199254740992, 9007199254740993, true},
+		{"beyond int64", 9, 1e30, math.MaxInt64, true},
+		{"out of range", 10, 0, 0, false},
+		{"$0", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, ok := ctx.FieldFloat(tt.index)
+			assertion.Equal(t, f, tt.float, "FieldFloat")
+			assertion.Equal(t, ok, tt.number, "FieldFloat ok")
+			n, ok := ctx.FieldInt(tt.index)
+			assertion.Equal(t, n, tt.int, "FieldInt")
+			assertion.Equal(t, ok, tt.number, "FieldInt ok")
+		})
+	}
+
+	ctx.Fields[0] = " 12 "
+	n, ok := ctx.FieldInt(0)
+	assertion.Equal(t, [2]any{n, ok}, [2]any{int64(12), true}, "$0 is a field too")
+}
+
+func TestContext_Join(t *testing.T) {
+	ctx := &command.Context{
+		Fields: []string{"a:b:c:d", "a", "b", "c", "d"},
+		NF:     4,
+		OFS:    " ",
+	}
+
+	tests := []struct {
+		name     string
+		from, to int
+		want     string
+	}{
+		{"from field 3 onward", 3, 0, "c d"},
+		{"to -1 is NF", 2, -1, "b c d"},
+		{"inner range", 2, 3, "b c"},
+		{"single field", 4, 4, "d"},
+		{"negative from", -2, 0, "c d"},
+		{"negative to", 1, -2, "a b c"},
+		{"to past NF", 3, 10, "c d"},
+		{"from past NF", 5, 0, ""},
+		{"reversed", 3, 2, ""},
+		{"from $0", 0, 2, ""},
+		{"negative beyond NF", -5, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion.Equal(t, ctx.Join(tt.from, tt.to), tt.want, "joined fields")
+		})
+	}
+
+	ctx.OFS = "|"
+	assertion.Equal(t, ctx.JoinAll(), "a|b|c|d", "rebuilt with OFS")
+	assertion.Equal(t, (&command.Context{}).JoinAll(), "", "no fields")
+}
+
+func TestAwk_JoinFields(t *testing.T) {
+	result := run.Command(command.Awk(StripProgram{}, command.FieldSeparator(","), command.OutputFieldSeparator(";"))).
+		WithStdinLines("id,date,x,y", "1,2").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"x;y|id;date;x;y", "|1;2"})
+}
+
+// StripProgram strips the first two columns, and prints the record rebuilt with OFS
+type StripProgram struct {
+	command.SimpleProgram
+}
+
+func (p StripProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Join(3, 0) + "|" + ctx.JoinAll(), true
+}
+
 func TestContext_Var(t *testing.T) {
 	ctx := &command.Context{
 		Variables: map[string]any{
@@ -83,6 +285,50 @@ func TestContext_Var(t *testing.T) {
 	assertion.True(t, nilCtx.Var("any") == nil, "var on nil map should be nil")
 }
 
+func TestContext_VarInt_VarFloat_VarString(t *testing.T) {
+	ctx := &command.Context{
+		Variables: map[string]any{
+			"prefix":   "42abc",
+			"integral": 3.0,
+			"fraction": 2.75,
+			"yes":      true,
+			"no":       false,
+			"nil":      nil,
+			"negative": int32(-7),
+			"word":     "abc",
+		},
+		OFMT: "%.1f",
+	}
+
+	tests := []struct {
+		name  string
+		float float64
+		int   int64
+		str   string
+	}{
+		{"prefix", 42, 42, "42abc"},
+		{"integral", 3, 3, "3"},
+		{"fraction", 2.75, 2, "2.8"},
+		{"yes", 1, 1, "1"},
+		{"no", 0, 0, "0"},
+		{"nil", 0, 0, ""},
+		{"missing", 0, 0, ""},
+		{"negative", -7, -7, "-7"},
+		{"word", 0, 0, "abc"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion.Equal(t, ctx.VarFloat(tt.name), tt.float, "VarFloat")
+			assertion.Equal(t, ctx.VarInt(tt.name), tt.int, "VarInt")
+			assertion.Equal(t, ctx.VarString(tt.name), tt.str, "VarString")
+		})
+	}
+
+	empty := &command.Context{}
+	assertion.Equal(t, empty.VarInt("x"), int64(0), "VarInt without variables")
+}
+
 func TestContext_SetVar(t *testing.T) {
 	ctx := &command.Context{}
 
@@ -101,6 +347,65 @@ func TestContext_SetVar(t *testing.T) {
 	assertion.Equal(t, ctx.Var("bool"), true, "bool variable")
 }
 
+func TestContext_VarNames(t *testing.T) {
+	ctx := &command.Context{}
+	assertion.Equal(t, len(ctx.VarNames()), 0, "no variables")
+
+	for _, name := range []string{"zeta", "alpha", "mid", "Beta", "alpha2"} {
+		ctx.SetVar(name, 1)
+	}
+	want := []string{"Beta", "alpha", "alpha2", "mid", "zeta"}
+	for range 10 {
+		assertion.Equal(t, ctx.VarNames(), want, "sorted names")
+	}
+
+	ctx.DeleteVar("mid")
+	ctx.DeleteVar("missing")
+	assertion.Equal(t, ctx.VarNames(), []string{"Beta", "alpha", "alpha2", "zeta"}, "after delete")
+	assertion.True(t, ctx.Var("mid") == nil, "deleted variable is gone")
+
+	ctx.ClearVars()
+	assertion.Equal(t, len(ctx.VarNames()), 0, "after clear")
+
+	// Still usable after clearing
+	ctx.SetVar("x", 1)
+	assertion.Equal(t, ctx.VarNames(), []string{"x"}, "set after clear")
+}
+
+// DumpVarsProgram sets a few variables and dumps them all in End
+type DumpVarsProgram struct {
+	command.SimpleProgram
+}
+
+func (p DumpVarsProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.AddVar(ctx.Field(1), 1)
+	return "", false
+}
+
+func (p DumpVarsProgram) End(ctx *command.Context) (string, error) {
+	lines := make([]string, 0)
+	for _, name := range ctx.VarNames() {
+		lines = append(lines, fmt.Sprintf("%s=%v", name, ctx.Var(name)))
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func TestAwk_VarNames_StableDump(t *testing.T) {
+	for range 5 {
+		result := run.Command(command.Awk(DumpVarsProgram{}, command.Variable{Name: "init", Value: 0})).
+			WithStdinLines("b", "c", "a", "b").Run()
+
+		assertion.NoError(t, result.Err)
+		assertion.Lines(t, result.Stdout, []string{"a=1", "b=2", "c=1", "init=0"})
+	}
+}
+
+func TestContext_Print_OFMT(t *testing.T) {
+	ctx := &command.Context{OFS: " ", OFMT: "%.2f"}
+
+	assertion.Equal(t, ctx.Print(10.0/3, 2.0, 7, "x"), "3.33 2 7 x", "OFMT applies to non-integral floats only")
+}
+
 func TestContext_Print(t *testing.T) {
 	ctx := &command.Context{OFS: "|"}
 
@@ -113,6 +418,10 @@ func TestContext_Print(t *testing.T) {
 		{"single value", []any{"single"}, "single"},
 		{"empty", []any{}, ""},
 		{"mixed types", []any{1, "two", 3.0}, "1|two|3"},
+		{"float through OFMT", []any{0.1 + 0.2}, "0.3"},
+		{"float32", []any{float32(2.5)}, "2.5"},
+		{"OFMT precision", []any{3.14159265}, "3.14159"},
+		{"large float", []any{1e21}, "1e+21"},
 	}
 
 	for _, tt := range tests {
@@ -123,6 +432,170 @@ func TestContext_Print(t *testing.T) {
 	}
 }
 
+type celsius float64
+type hitCount uint16
+
+func TestContext_Print_Numbers(t *testing.T) {
+	ctx := &command.Context{OFS: " "}
+
+	tests := []struct {
+		name  string
+		value any
+		want  string
+	}{
+		{"int", -42, "-42"},
+		{"int8", int8(-8), "-8"},
+		{"int16", int16(16), "16"},
+		{"int32", int32(-32), "-32"},
+		{"int64", int64(math.MinInt64), "-9223372036854775808"},
+		{"uint", uint(7), "7"},
+		{"uint8", uint8(255), "255"},
+		{"uint16", uint16(16), "16"},
+		{"uint32", uint32(math.MaxUint32), "4294967295"},
+		{"uint64 past int64", uint64(math.MaxUint64), "18446744073709551615"},
+		{"uintptr", uintptr(9), "9"},
+		{"integral float64", 3.0, "3"},
+		{"integral float32", float32(3), "3"},
+		{"fractional float64", 2.5, "2.5"},
+		{"fractional float32", float32(0.1), "0.1"},
+		{"negative zero", math.Copysign(0, -1), "0"},
+		{"largest integral float in int64", float64(1 << 62), "4611686018427387904"},
+		{"2^63 is past int64", float64(1 << 63), "9.22337e+18"},
+		{"huge float", 1e21, "1e+21"},
+		{"tiny float", 1e-7, "1e-07"},
+		{"named float", celsius(21.5), "21.5"},
+		{"named integral float", celsius(20), "20"},
+		{"named uint", hitCount(3), "3"},
+		{"Stringer", time.Second, "1s"},
+		{"bool", true, "true"},
+		{"nil", nil, "<nil>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion.Equal(t, ctx.Print(tt.value), tt.want, "rendering")
+		})
+	}
+
+	ctx.OFMT = "%.2f"
+	assertion.Equal(t, ctx.Print(celsius(1.0/3), float32(2.5), uint8(1)), "0.33 2.50 1", "OFMT applies to every float type")
+}
+
+func TestContext_Substr_Length(t *testing.T) {
+	ctx := &command.Context{}
+	assertion.Equal(t, ctx.Length("日本語"), 3, "characters")
+	assertion.Equal(t, ctx.Substr("日本語", 2, 1), "本", "character substring")
+
+	ctx.BytesMode = true
+	assertion.Equal(t, ctx.Length("日本語"), 9, "bytes")
+	assertion.Equal(t, ctx.Substr("日本語", 4, 3), "本", "byte substring")
+}
+
+func TestContext_Index_Match(t *testing.T) {
+	ctx := &command.Context{}
+	assertion.Equal(t, ctx.Index("日本語", "語"), 3, "character position")
+	assertion.Equal(t, ctx.Index("日本語", "x"), 0, "not found")
+	start, length := ctx.Match("日本本語", `本+`)
+	assertion.Equal(t, [2]int{start, length}, [2]int{2, 2}, "character RSTART and RLENGTH")
+	assertion.Equal(t, [2]int{ctx.RStart, ctx.RLength}, [2]int{2, 2}, "RStart and RLength")
+	assertion.Equal(t, ctx.Substr("日本本語", ctx.RStart, ctx.RLength), "本本", "Substr of the match")
+	start, length = ctx.Match("abc", `本+`)
+	assertion.Equal(t, [2]int{start, length}, [2]int{0, -1}, "no match")
+	assertion.Equal(t, [2]int{ctx.RStart, ctx.RLength}, [2]int{0, -1}, "RStart and RLength without match")
+	start, length = ctx.Match("abc", `x*`)
+	assertion.Equal(t, [2]int{start, length}, [2]int{1, 0}, "empty match")
+
+	ctx.BytesMode = true
+	assertion.Equal(t, ctx.Index("日本語", "語"), 7, "byte position")
+	start, length = ctx.Match("日本本語", `本+`)
+	assertion.Equal(t, [2]int{start, length}, [2]int{4, 6}, "byte RSTART and RLENGTH")
+}
+
+func TestContext_Match_Longest(t *testing.T) {
+	ctx := &command.Context{}
+	start, length := ctx.Match("xabc", `a|ab`)
+	assertion.Equal(t, [2]int{start, length}, [2]int{2, 2}, "the longest alternative wins")
+	start, length = ctx.Match("xabc", `(a|ab)(c|bcd)?`)
+	assertion.Equal(t, [2]int{start, length}, [2]int{2, 3}, "leftmost-longest overall")
+
+	re, err := ctx.Regexp(`a|ab`)
+	assertion.NoError(t, err)
+	assertion.Equal(t, re.FindString("xabc"), "a", "Regexp keeps leftmost-first matching")
+}
+
+// MatchProgram prints the first number of each record, like
+// awk 'match($0, /[0-9]+/) { print substr($0, RSTART, RLENGTH) }'
+type MatchProgram struct {
+	command.SimpleProgram
+}
+
+func (p MatchProgram) Condition(ctx *command.Context) bool {
+	start, _ := ctx.Match(ctx.Field(0), `[0-9]+`)
+	return start > 0
+}
+
+func (p MatchProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Substr(ctx.Field(0), ctx.RStart, ctx.RLength), true
+}
+
+func TestAwk_Match(t *testing.T) {
+	result := run.Command(command.Awk(MatchProgram{})).
+		WithStdinLines("été 2024 août", "none", "x(").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"2024"})
+}
+
+func TestAwk_Match_InvalidPattern(t *testing.T) {
+	result := run.Command(command.Awk(BadPatternProgram{match: true})).WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "record 1: error parsing regexp")
+}
+
+func TestContext_Split(t *testing.T) {
+	ctx := &command.Context{}
+
+	tests := []struct {
+		name string
+		s    string
+		sep  string
+		want []string
+	}{
+		{"empty input", "", ",", []string{}},
+		{"empty input, whitespace", "", " ", []string{}},
+		{"whitespace runs", "  a \t b\n c  ", " ", []string{"a", "b", "c"}},
+		{"single character", "a,b,,c", ",", []string{"a", "b", "", "c"}},
+		{"trailing separator", "a,b,", ",", []string{"a", "b", ""}},
+		{"regexp metacharacter is literal", "a.b.c", ".", []string{"a", "b", "c"}},
+		{"regexp", "a1b22c", "[0-9]+", []string{"a", "b", "c"}},
+		{"regexp with trailing separator", "a, b,  ", ", *", []string{"a", "b", ""}},
+		{"characters", "日本", "", []string{"日", "本"}},
+		{"no separator", "abc", ";", []string{"abc"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion.Equal(t, ctx.Split(tt.s, tt.sep), tt.want, "Split")
+		})
+	}
+
+	ctx.BytesMode = true
+	assertion.Count(t, ctx.Split("日本", ""), 6)
+}
+
+func TestContext_SplitInto(t *testing.T) {
+	ctx := &command.Context{}
+	m := map[int]string{1: "old", 7: "stale"}
+
+	n := ctx.SplitInto("x:y:z", ":", m)
+	assertion.Equal(t, n, 3, "elements")
+	assertion.Equal(t, m, map[int]string{1: "x", 2: "y", 3: "z"}, "array")
+
+	n = ctx.SplitInto("", ":", m)
+	assertion.Equal(t, n, 0, "empty input")
+	assertion.Equal(t, m, map[int]string{}, "array cleared")
+}
+
 // ==============================================================================
 // Test SimpleProgram Default Behavior
 // ==============================================================================
@@ -295,6 +768,15 @@ func TestAwk_FieldSplitting_DefaultWhitespace(t *testing.T) {
 	assertion.Lines(t, result.Stdout, []string{"a"})
 }
 
+func TestAwk_FieldAccess_LastField(t *testing.T) {
+	// awk '{print $NF}'
+	result := run.Command(command.Awk(FieldExtractorProgram{fieldIndex: -1})).
+		WithStdinLines("a b c", "single", "").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"c", "single", ""})
+}
+
 func TestAwk_FieldAccess_OutOfBounds(t *testing.T) {
 	// Test accessing fields beyond what exists returns empty string
 	result := run.Command(command.Awk(FieldExtractorProgram{fieldIndex: 10})).
@@ -763,7 +1245,7 @@ func TestAwk_AwkCompatibility_EmptyLineFields(t *testing.T) {
 	assertion.NoError(t, result.Err)
 	assertion.Lines(t, result.Stdout, []string{
 		"NF=2 $1=[a] $2=[b]",
-		"NF=0 $1=[] $2=[]",  // Empty line: NF=0, fields are empty
+		"NF=0 $1=[] $2=[]", // Empty line: NF=0, fields are empty
 		"NF=2 $1=[x] $2=[y]",
 	})
 }
@@ -823,3 +1305,695 @@ func TestAwk_TableDriven(t *testing.T) {
 	}
 }
 
+// ==============================================================================
+// Test Environment (ENVIRON)
+// ==============================================================================
+
+// EnvironProgram prints environment variables named by $1
+type EnvironProgram struct {
+	command.SimpleProgram
+}
+
+func (p EnvironProgram) Action(ctx *command.Context) (string, bool) {
+	return fmt.Sprintf("%s=[%s]", ctx.Field(1), ctx.Environ(ctx.Field(1))), true
+}
+
+func TestAwk_Environment(t *testing.T) {
+	result := run.Command(
+		command.Awk(
+			EnvironProgram{},
+			command.Environment{"THRESHOLD": "100", "OUTDIR": "/tmp/out"},
+		),
+	).WithStdinLines("THRESHOLD", "OUTDIR", "MISSING").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"THRESHOLD=[100]",
+		"OUTDIR=[/tmp/out]",
+		"MISSING=[]",
+	})
+}
+
+func TestAwk_Environment_DefaultsToProcess(t *testing.T) {
+	t.Setenv("YUPSH_AWK_TEST", "from-process")
+
+	result := run.Command(command.Awk(EnvironProgram{})).
+		WithStdinLines("YUPSH_AWK_TEST").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"YUPSH_AWK_TEST=[from-process]"})
+}
+
+// EnvironMapProgram mutates the map returned by EnvironMap
+type EnvironMapProgram struct {
+	command.SimpleProgram
+}
+
+func (p EnvironMapProgram) Action(ctx *command.Context) (string, bool) {
+	env := ctx.EnvironMap()
+	env["HOME"] = "changed"
+	return fmt.Sprintf("%d %s", len(env), ctx.Environ("HOME")), true
+}
+
+func TestAwk_EnvironMap_IsCopy(t *testing.T) {
+	result := run.Command(
+		command.Awk(
+			EnvironMapProgram{},
+			command.Environment{"HOME": "/home/user"},
+		),
+	).WithStdinLines("line").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1 /home/user"})
+}
+
+// ==============================================================================
+// Test Execution Context
+// ==============================================================================
+
+// CancellingProgram cancels the run after the given record
+type CancellingProgram struct {
+	command.SimpleProgram
+	cancel  context.CancelFunc
+	afterNR int64
+	sawErr  []bool
+}
+
+func (p *CancellingProgram) Begin(ctx *command.Context) error {
+	p.sawErr = append(p.sawErr, ctx.Context().Err() != nil)
+	return nil
+}
+
+func (p *CancellingProgram) Action(ctx *command.Context) (string, bool) {
+	if ctx.NR == p.afterNR {
+		p.cancel()
+	}
+	p.sawErr = append(p.sawErr, ctx.Context().Err() != nil)
+	return ctx.Field(0), true
+}
+
+func TestAwk_ContextCancellation(t *testing.T) {
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	prog := &CancellingProgram{cancel: cancel, afterNR: 2}
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(prog).Executor()(runCtx, strings.NewReader("a\nb\nc\nd\n"), &stdout, &stderr)
+
+	assertion.True(t, errors.Is(err, context.Canceled), "run should stop with context.Canceled")
+	assertion.Equal(t, stdout.String(), "a\nb\n", "records after cancellation are not processed")
+	assertion.Equal(t, joinBools(prog.sawErr), "false false true", "ctx.Context() reflects the run context")
+}
+
+func TestContext_Context_DefaultsToBackground(t *testing.T) {
+	ctx := &command.Context{}
+	assertion.True(t, ctx.Context() != nil, "context should never be nil")
+	assertion.NoError(t, ctx.Context().Err())
+}
+
+func joinBools(values []bool) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatBool(v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// ==============================================================================
+// Test StartNR
+// ==============================================================================
+
+// FinalNRProgram numbers lines and reports the final NR from End
+type FinalNRProgram struct {
+	LineNumberProgram
+}
+
+func (p FinalNRProgram) End(ctx *command.Context) (string, error) {
+	return fmt.Sprintf("last NR=%d", ctx.NR), nil
+}
+
+func TestAwk_StartNR(t *testing.T) {
+	result := run.Command(command.Awk(FinalNRProgram{}, command.StartNR(100))).
+		WithStdinLines("first", "second").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"101: first",
+		"102: second",
+		"last NR=102",
+	})
+}
+
+func TestAwk_StartNR_EmptyInput(t *testing.T) {
+	// With no input, End reports the seed so a checkpoint is carried over unchanged
+	result := run.Quick(command.Awk(FinalNRProgram{}, command.StartNR(42)))
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"last NR=42"})
+}
+
+// ==============================================================================
+// Test Shared Variables
+// ==============================================================================
+
+func TestContext_AddVar(t *testing.T) {
+	ctx := &command.Context{}
+
+	assertion.Equal(t, ctx.AddVar("n", 2), 2.0, "unset starts at 0")
+	assertion.Equal(t, ctx.Var("n"), 2, "integral delta on unset stays int")
+
+	ctx.SetVar("count", 10)
+	assertion.Equal(t, ctx.AddVar("count", 1), 11.0, "int add")
+	assertion.Equal(t, ctx.Var("count"), 11, "int stays int")
+
+	assertion.Equal(t, ctx.AddVar("count", 0.5), 11.5, "fractional add")
+	assertion.Equal(t, ctx.Var("count"), 11.5, "int becomes float")
+
+	ctx.SetVar("big", int64(1))
+	ctx.AddVar("big", 1)
+	assertion.Equal(t, ctx.Var("big"), int64(2), "int64 stays int64")
+
+	ctx.SetVar("str", " 2.5 ")
+	assertion.Equal(t, ctx.AddVar("str", 1), 3.5, "numeric string")
+}
+
+func TestContext_AddVar_OtherTypes(t *testing.T) {
+	ctx := &command.Context{}
+	values := map[string]any{"int32": int32(5), "uint": uint(5), "float32": float32(5), "bool": true, "prefix": "42abc", "text": "abc"}
+	want := map[string]float64{"int32": 6, "uint": 6, "float32": 6, "bool": 2, "prefix": 43, "text": 1}
+	for name, value := range values {
+		ctx.SetVar(name, value)
+		assertion.Equal(t, ctx.AddVar(name, 1), want[name], name)
+		assertion.Equal(t, ctx.VarFloat(name), want[name], name+" read back like VarFloat")
+	}
+}
+
+// ParallelSumProgram fans each record out to goroutines accumulating into one variable
+type ParallelSumProgram struct {
+	command.SimpleProgram
+	workers int
+}
+
+func (p ParallelSumProgram) Action(ctx *command.Context) (string, bool) {
+	value, _ := strconv.ParseFloat(ctx.Field(1), 64)
+
+	var wg sync.WaitGroup
+	for range p.workers {
+		wg.Go(func() {
+			ctx.AddVar("total", value)
+			ctx.SetVar("last", ctx.Var("total"))
+		})
+	}
+	wg.Wait()
+	return "", false
+}
+
+func (p ParallelSumProgram) End(ctx *command.Context) (string, error) {
+	return fmt.Sprintf("total=%v", ctx.Var("total")), nil
+}
+
+// numbers returns the lines "1" to n
+func numbers(n int) []string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = strconv.Itoa(i + 1)
+	}
+	return lines
+}
+
+func TestAwk_SharedVariables_Parallel(t *testing.T) {
+	result := run.Command(command.Awk(ParallelSumProgram{workers: 8}, command.SharedVariables(true))).
+		WithStdinLines(numbers(100)...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"total=40400"}) // 8 * (1+...+100)
+}
+
+// ReportProgram prints a computed average
+type ReportProgram struct {
+	command.SimpleProgram
+}
+
+func (p ReportProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Print(ctx.Field(1), 10.0/3), true
+}
+
+func TestAwk_OutputFormat(t *testing.T) {
+	result := run.Command(command.Awk(ReportProgram{}, command.OutputFormat("%.2f"))).
+		WithStdinLines("avg").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"avg 3.33"})
+
+	result = run.Command(command.Awk(ReportProgram{})).
+		WithStdinLines("avg").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"avg 3.33333"})
+}
+
+// PrefixProgram prints the length and first two characters of $0
+type PrefixProgram struct {
+	command.SimpleProgram
+}
+
+func (p PrefixProgram) Action(ctx *command.Context) (string, bool) {
+	return fmt.Sprintf("%d %q", ctx.Length(ctx.Field(0)), ctx.Substr(ctx.Field(0), 1, 2)), true
+}
+
+func TestAwk_BytesMode(t *testing.T) {
+	result := run.Command(command.Awk(PrefixProgram{})).
+		WithStdinLines("日本語", "abc").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{`3 "日本"`, `3 "ab"`})
+
+	result = run.Command(command.Awk(PrefixProgram{}, command.BytesMode(true))).
+		WithStdinLines("日本語", "abc").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{`9 "\xe6\x97"`, `3 "ab"`})
+}
+
+// CharsProgram splits records into characters and reports where "!" and the
+// first run of b's are
+type CharsProgram struct {
+	command.SimpleProgram
+}
+
+func (p CharsProgram) Begin(ctx *command.Context) error {
+	ctx.FS = ""
+	return nil
+}
+
+func (p CharsProgram) Action(ctx *command.Context) (string, bool) {
+	start, length := ctx.Match(ctx.Field(0), `b+`)
+	return fmt.Sprintf("NF=%d length=%d index=%d match=%d,%d $2=%q",
+		ctx.NF, ctx.Length(ctx.Field(0)), ctx.Index(ctx.Field(0), "!"), start, length, ctx.Field(2)), true
+}
+
+func TestAwk_BytesMode_InvalidUTF8(t *testing.T) {
+	// Each byte that is not valid UTF-8 is a character of its own
+	path := writeFile(t, "binary.txt", "\xffé\xfebb!")
+	result := run.Command(command.Awk(CharsProgram{}, path)).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{`NF=6 length=6 index=6 match=4,2 $2="é"`})
+
+	result = run.Command(command.Awk(CharsProgram{}, path, command.BytesMode(true))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{`NF=7 length=7 index=7 match=5,2 $2="\xc3"`})
+}
+
+// ==============================================================================
+// Test Changing Separators From a Program
+// ==============================================================================
+
+// BeginFSProgram sets FS and OFS in Begin, like BEGIN{FS=","; OFS="|"}
+type BeginFSProgram struct {
+	command.SimpleProgram
+}
+
+func (p BeginFSProgram) Begin(ctx *command.Context) error {
+	ctx.FS = ","
+	ctx.OFS = "|"
+	return nil
+}
+
+func (p BeginFSProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Print(ctx.Field(2), ctx.Field(1)), true
+}
+
+func TestAwk_FS_SetInBegin(t *testing.T) {
+	result := run.Command(command.Awk(BeginFSProgram{})).
+		WithStdinLines("a,b c", "d,e").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"b c|a",
+		"e|d",
+	})
+}
+
+// BeginSettingsProgram configures the run in Begin, like
+// BEGIN{FS=","; OFS="-"; RS=";"; ORS="|"; OFMT="%.1f"}
+type BeginSettingsProgram struct {
+	command.SimpleProgram
+}
+
+func (p BeginSettingsProgram) Begin(ctx *command.Context) error {
+	ctx.FS, ctx.OFS, ctx.RS, ctx.ORS, ctx.OFMT = ",", "-", ";", "|", "%.1f"
+	return nil
+}
+
+func (p BeginSettingsProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Print(ctx.NR, ctx.Field(2), ctx.Field(1), 0.25), true
+}
+
+func (p BeginSettingsProgram) End(ctx *command.Context) (string, error) {
+	return ctx.Print("end", 1.75), nil
+}
+
+func TestAwk_SettingsInBegin(t *testing.T) {
+	output := execute(t, BeginSettingsProgram{}, "a,b;c,d;")
+	assertion.Equal(t, output, "1-b-a-0.2|2-d-c-0.2|end-1.8|", "Begin configures splitting and output")
+
+	// Begin overrides the options, as BEGIN overrides awk -v
+	output = execute(t, BeginSettingsProgram{}, "a,b;",
+		command.FieldSeparator(":"), command.RecordSeparator("\n"), command.OutputRecordSeparator("\n"))
+	assertion.Equal(t, output, "1-b-a-0.2|end-1.8|", "Begin wins over options")
+}
+
+func TestPipe_SettingsInBegin(t *testing.T) {
+	// The second stage gets the first's records without their ORS
+	var stdout, stderr bytes.Buffer
+	cmd := command.Pipe(BeginSettingsProgram{}, LineNumberProgram{})
+	err := cmd.Executor()(context.Background(), strings.NewReader("x,y;"), &stdout, &stderr)
+
+	assertion.NoError(t, err)
+	assertion.Equal(t, stdout.String(), "1: 1-y-x-0.2\n2: end-1.8\n", "first stage configured in Begin")
+}
+
+// SwitchFSProgram switches to ":" after a "--" marker record
+type SwitchFSProgram struct {
+	command.SimpleProgram
+}
+
+func (p SwitchFSProgram) Action(ctx *command.Context) (string, bool) {
+	if ctx.Field(0) == "--" {
+		ctx.FS = ":"
+		return "", false
+	}
+	return fmt.Sprintf("NF=%d $1=%s", ctx.NF, ctx.Field(1)), true
+}
+
+func TestAwk_FS_SetAtRecordN(t *testing.T) {
+	result := run.Command(command.Awk(SwitchFSProgram{})).
+		WithStdinLines("a b:c", "d e:f", "--", "g h:i").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"NF=2 $1=a",
+		"NF=2 $1=d",
+		"NF=2 $1=g h",
+	})
+}
+
+// ChangeFSMidRecordProgram changes FS during Action and inspects the current record
+type ChangeFSMidRecordProgram struct {
+	command.SimpleProgram
+}
+
+func (p ChangeFSMidRecordProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.FS = ":"
+	return fmt.Sprintf("NF=%d", ctx.NF), true
+}
+
+func TestAwk_FS_ChangeAppliesToNextRecord(t *testing.T) {
+	// echo -e "a:b:c d\nx:y:z w" | awk '{FS=":"; print "NF="NF}'
+	result := run.Command(command.Awk(ChangeFSMidRecordProgram{})).
+		WithStdinLines("a:b:c d", "x:y:z w").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"NF=2", // still split on whitespace
+		"NF=3",
+	})
+}
+
+// SwapProgram prints $2 and $1, like awk '{print $2, $1}'
+type SwapProgram struct {
+	command.SimpleProgram
+}
+
+func (p SwapProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Print(ctx.Field(2), ctx.Field(1), 0.125), true
+}
+
+func TestAwk_Variable_BuiltIns(t *testing.T) {
+	// awk -v FS=, -v OFS='|' -v ORS=';' -v OFMT=%.1f '{print $2, $1, 0.125}'
+	input := []string{"a,b", "c,d"}
+	byOptions := run.Command(command.Awk(SwapProgram{},
+		command.FieldSeparator(","), command.OutputFieldSeparator("|"),
+		command.OutputRecordSeparator(";"), command.OutputFormat("%.1f"))).
+		WithStdinLines(input...).Run()
+	byVariables := run.Command(command.Awk(SwapProgram{},
+		command.Variable{Name: "FS", Value: ","}, command.Variable{Name: "OFS", Value: "|"},
+		command.Variable{Name: "ORS", Value: ";"}, command.Variable{Name: "OFMT", Value: "%.1f"})).
+		WithStdinLines(input...).Run()
+
+	assertion.NoError(t, byOptions.Err)
+	assertion.NoError(t, byVariables.Err)
+	assertion.Equal(t, byVariables.Stdout, byOptions.Stdout, "same output")
+	assertion.Lines(t, byVariables.Stdout, []string{"b|a|0.1;d|c|0.1;"})
+}
+
+func TestAwk_Variable_BuiltInsNotInVariables(t *testing.T) {
+	result := run.Command(command.Awk(DumpVarsProgram{}, command.Variable{Name: "OFS", Value: "|"},
+		command.Variable{Name: "RS", Value: ";"})).
+		WithStdinLines("x").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"x=1"})
+}
+
+func TestAwk_Variable_LastWins(t *testing.T) {
+	output := execute(t, SwapProgram{}, "a b\n",
+		command.Variable{Name: "OFS", Value: "|"}, command.OutputFieldSeparator(","))
+	assertion.Equal(t, output, "b,a,0.125\n", "the option comes last")
+
+	output = execute(t, SwapProgram{}, "a b\n",
+		command.OutputFieldSeparator(","), command.Variable{Name: "OFS", Value: "|"})
+	assertion.Equal(t, output, "b|a|0.125\n", "the variable comes last")
+}
+
+func TestAwk_Variable_RS(t *testing.T) {
+	output := execute(t, LineNumberProgram{}, "a;b;", command.Variable{Name: "RS", Value: ";"})
+	assertion.Equal(t, output, "1: a\n2: b\n", "RS from a variable")
+
+	_, err := command.AwkE(SwapProgram{}, command.Variable{Name: "RS", Value: ";"},
+		command.RecordSplitter(command.ScanContinuationLines))
+	assertion.ErrorContains(t, err, "RecordSplitter cannot be combined with RecordSeparator")
+}
+
+func TestAwkE_Variable_EngineOwned(t *testing.T) {
+	_, err := command.AwkE(SwapProgram{}, command.Variable{Name: "NR", Value: 5}, command.Variable{Name: "NF", Value: 1})
+	assertion.ErrorContains(t, err, "invalid Variable NR: set by the engine for every record")
+	assertion.ErrorContains(t, err, "invalid Variable NF")
+}
+
+// ==============================================================================
+// Test File Operands
+// ==============================================================================
+
+// writeFile creates a file with the given lines in a temporary directory
+func writeFile(t *testing.T, name string, lines ...string) string {
+	t.Helper()
+	return writeBytes(t, name, []byte(strings.Join(lines, "\n")+"\n"))
+}
+
+// writeBytes creates a file with the given content in a temporary directory
+func writeBytes(t *testing.T, name string, content []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	assertion.NoError(t, os.WriteFile(path, content, 0o644))
+	return path
+}
+
+func TestAwk_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "a", "b")
+	second := writeFile(t, "second.txt", "c")
+
+	result := run.Command(command.Awk(LineNumberProgram{}, first, second)).
+		WithStdinLines("ignored").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: a", "2: b", "3: c"})
+}
+
+func TestAwk_Files_StdinBetween(t *testing.T) {
+	header := writeFile(t, "header.txt", "header")
+	footer := writeFile(t, "footer.txt", "footer")
+
+	// A second "-" finds stdin at its end
+	var stats command.Stats
+	result := run.Command(command.Awk(LineNumberProgram{}, header, "-", footer, "-", command.StatsRecipient(&stats))).
+		WithStdinLines("piped 1", "piped 2").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: header", "2: piped 1", "3: piped 2", "4: footer"})
+	assertion.Equal(t, fmt.Sprint(stats.Files),
+		fmt.Sprintf("[{%s 1} {- 2} {%s 1} {- 0}]", header, footer), "stdin is named -")
+
+	// "-" alone is the same as no operand
+	result = run.Command(command.Awk(LineNumberProgram{}, "-")).WithStdinLines("x").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: x"})
+}
+
+// FileRecordProgram prints where every record comes from
+type FileRecordProgram struct {
+	command.SimpleProgram
+}
+
+func (p FileRecordProgram) Action(ctx *command.Context) (string, bool) {
+	return fmt.Sprintf("%s %d %d %s", ctx.Filename, ctx.FNR, ctx.NR, ctx.Field(0)), true
+}
+
+func TestAwk_Files_FNR(t *testing.T) {
+	a, b := writeFile(t, "a.txt", "a1", "a2"), writeFile(t, "b.txt", "b1")
+	result := run.Command(command.Awk(FileRecordProgram{}, a, "-", b)).WithStdinLines("s1").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		a + " 1 1 a1", a + " 2 2 a2", "- 1 3 s1", b + " 1 4 b1",
+	})
+
+	// Without operands stdin is unnamed, and FNR follows NR
+	result = run.Command(command.Awk(FileRecordProgram{}, command.StartNR(10))).WithStdinLines("x").Run()
+	assertion.Lines(t, result.Stdout, []string{" 1 11 x"})
+
+	// The records kept by TailRecords keep where they came from
+	result = run.Command(command.Awk(FileRecordProgram{}, a, b, command.TailRecords(2))).Run()
+	assertion.Lines(t, result.Stdout, []string{a + " 2 2 a2", b + " 1 3 b1"})
+}
+
+func TestAwk_Files_Missing(t *testing.T) {
+	result := run.Command(command.Awk(command.SimpleProgram{}, filepath.Join(t.TempDir(), "missing.txt"))).
+		WithStdinLines("ignored").Run()
+
+	assertion.ErrorContains(t, result.Err, "missing.txt")
+}
+
+func TestAwk_Files_Reusable(t *testing.T) {
+	// Files are opened on every run, so a command can be executed twice
+	cmd := command.Awk(command.SimpleProgram{}, writeFile(t, "data.txt", "x"))
+
+	for range 2 {
+		result := run.Command(cmd).Run()
+		assertion.NoError(t, result.Err)
+		assertion.Lines(t, result.Stdout, []string{"x"})
+	}
+}
+
+// DedupProgram prints each distinct line once
+type DedupProgram struct {
+	command.SimpleProgram
+	seen map[string]bool
+}
+
+func (p *DedupProgram) Condition(ctx *command.Context) bool {
+	if p.seen == nil {
+		p.seen = make(map[string]bool)
+	}
+	if p.seen[ctx.Field(0)] {
+		return false
+	}
+	p.seen[ctx.Field(0)] = true
+	return true
+}
+
+func (p *DedupProgram) Reset() {
+	p.seen = nil
+}
+
+// DistinctVarProgram counts distinct lines in a variable and reports it in End
+type DistinctVarProgram struct {
+	DedupProgram
+}
+
+func (p *DistinctVarProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.AddVar("distinct", 1)
+	return ctx.Field(0), true
+}
+
+func (p *DistinctVarProgram) End(ctx *command.Context) (string, error) {
+	return fmt.Sprintf("distinct=%v start=%v", ctx.Var("distinct"), ctx.Var("start")), nil
+}
+
+func TestAwk_ResetPerFile(t *testing.T) {
+	first := writeFile(t, "first.txt", "x", "y", "x")
+	second := writeFile(t, "second.txt", "y", "z")
+
+	result := run.Command(command.Awk(&DistinctVarProgram{}, first, second,
+		command.Variable{Name: "start", Value: 1})).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"x", "y", "z", "distinct=3 start=1"})
+
+	result = run.Command(command.Awk(&DistinctVarProgram{}, first, second,
+		command.Variable{Name: "start", Value: 1}, command.ResetPerFile())).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"x", "y", "y", "z", "distinct=2 start=1"})
+}
+
+func TestAwk_ResetPerFile_ReadyMadePrograms(t *testing.T) {
+	first := writeFile(t, "first.txt", "k 1", "k 2")
+	second := writeFile(t, "second.txt", "k 5")
+
+	result := run.Command(command.Awk(command.GroupBy(1, 2, command.Sum), first, second)).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"k 8"})
+
+	result = run.Command(command.Awk(command.GroupBy(1, 2, command.Sum), first, second, command.ResetPerFile())).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"k 5"})
+}
+
+// ==============================================================================
+// Test Buffer Sizes
+// ==============================================================================
+
+func TestAwk_MaxRecordSize(t *testing.T) {
+	long := strings.Repeat("x", 100*1024)
+	input := "a\n" + long + "\nb\n"
+
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(UppercaseProgram{}).
+		Executor()(context.Background(), strings.NewReader(input), &stdout, &stderr)
+	assertion.ErrorContains(t, err, "record 2 is longer than 65536 bytes")
+
+	output := execute(t, UppercaseProgram{}, input, command.MaxRecordSize(1<<20))
+	assertion.Equal(t, output, strings.ToUpper(input), "long records fit under MaxRecordSize")
+
+	output = execute(t, UppercaseProgram{}, input, command.ReadBufferSize(1<<20))
+	assertion.Equal(t, output, strings.ToUpper(input), "a larger read buffer raises the limit")
+}
+
+func TestAwk_ReadBufferSize(t *testing.T) {
+	input := "alpha beta\ngamma\r\ndelta"
+
+	for _, size := range []int{1, 3, 4096} {
+		output := execute(t, FieldCountProgram{}, input, command.ReadBufferSize(size))
+		assertion.Equal(t, output, execute(t, FieldCountProgram{}, input), "output does not depend on the buffer size")
+
+		output = execute(t, command.SimpleProgram{}, input, command.ReadBufferSize(size))
+		assertion.Equal(t, output, "alpha beta\ngamma\ndelta\n", "pass-through output does not depend on the buffer size")
+	}
+}
+
+func TestAwk_WriteBufferSize(t *testing.T) {
+	result := run.Command(command.Awk(UppercaseProgram{}, command.WriteBufferSize(4))).
+		WithStdinLines("one", "two", "three").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"ONE", "TWO", "THREE"})
+
+	// Buffered output is flushed when the run fails
+	result = run.Command(command.Awk(ErrorInEndProgram{}, command.WriteBufferSize(1024))).
+		WithStdinLines("kept").Run()
+
+	assertion.Error(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"kept"})
+}
+
+func TestAwkE_BufferSizes(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{},
+		command.ReadBufferSize(0), command.WriteBufferSize(-1), command.MaxRecordSize(0))
+
+	assertion.ErrorContains(t, err, "invalid ReadBufferSize 0: must be positive")
+	assertion.ErrorContains(t, err, "invalid WriteBufferSize -1: must be positive")
+	assertion.ErrorContains(t, err, "invalid MaxRecordSize 0: must be positive")
+}
@@ -0,0 +1,116 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	gloo "github.com/gloo-foo/framework"
+	"github.com/gloo-foo/testable/assertion"
+	command "github.com/yupsh/awk"
+)
+
+// TaggedSumProgram numbers its records, tagged with the variable tag, and sums their first field in a variable
+type TaggedSumProgram struct {
+	command.SimpleProgram
+}
+
+func (p TaggedSumProgram) Action(ctx *command.Context) (string, bool) {
+	v, _ := strconv.Atoi(ctx.Field(1))
+	ctx.AddVar("sum", float64(v))
+	return fmt.Sprintf("%v%d %s", ctx.Var("tag"), ctx.NR, ctx.Field(0)), true
+}
+
+func (p TaggedSumProgram) End(ctx *command.Context) (string, error) {
+	return fmt.Sprint("sum=", ctx.Var("sum")), nil
+}
+
+// executeConcurrently executes cmd from 8 goroutines at once, each with its
+// own input, and checks every output with want
+func executeConcurrently(t *testing.T, cmd gloo.Command, input, want func(i int) string) {
+	t.Helper()
+	outputs := make([]bytes.Buffer, 8)
+	errs := make([]error, len(outputs))
+	var wg sync.WaitGroup
+	for i := range outputs {
+		wg.Go(func() {
+			errs[i] = cmd.Executor()(context.Background(), strings.NewReader(input(i)), &outputs[i], &bytes.Buffer{})
+		})
+	}
+	wg.Wait()
+
+	for i := range outputs {
+		assertion.NoError(t, errs[i])
+		assertion.Equal(t, outputs[i].String(), want(i), fmt.Sprintf("output of run %d", i))
+	}
+}
+
+// concurrentInput returns the input of run i of executeConcurrently: n
+// records whose only field is i times the record's index
+func concurrentInput(n int) func(i int) string {
+	return func(i int) string {
+		var input strings.Builder
+		for j := range n {
+			fmt.Fprintf(&input, "%d\n", i*j)
+		}
+		return input.String()
+	}
+}
+
+// sequentialOutput returns the output of cmd run alone over input(i)
+func sequentialOutput(t *testing.T, cmd gloo.Command, input func(i int) string) func(i int) string {
+	return func(i int) string {
+		var out bytes.Buffer
+		err := cmd.Executor()(context.Background(), strings.NewReader(input(i)), &out, &bytes.Buffer{})
+		assertion.NoError(t, err)
+		return out.String()
+	}
+}
+
+// sumOutput is the output of TaggedSumProgram tagged x over the input of run i
+func sumOutput(i int) string {
+	var want strings.Builder
+	for j := range 100 {
+		fmt.Fprintf(&want, "x%d %d\n", j+1, i*j)
+	}
+	fmt.Fprintf(&want, "sum=%d\n", i*4950)
+	return want.String()
+}
+
+func TestAwk_ConcurrentExecute(t *testing.T) {
+	cmd := command.Awk(TaggedSumProgram{}, command.Variable{Name: "tag", Value: "x"}, command.WriteBufferSize(64))
+	executeConcurrently(t, cmd, concurrentInput(100), sumOutput)
+}
+
+func TestPipe_ConcurrentExecute(t *testing.T) {
+	cmd := command.Pipe(TaggedSumProgram{}, LineNumberProgram{}, command.Variable{Name: "tag", Value: "x"})
+	executeConcurrently(t, cmd, concurrentInput(100), func(i int) string {
+		var want strings.Builder
+		for n, line := range strings.SplitAfter(strings.TrimSuffix(sumOutput(i), "\n"), "\n") {
+			fmt.Fprintf(&want, "%d: %s", n+1, line)
+		}
+		return want.String() + "\n"
+	})
+}
+
+func TestAwk_ConcurrentExecute_ReadyMadePrograms(t *testing.T) {
+	tmpl, err := command.Template("{{field 1}}/{{.NR}}")
+	assertion.NoError(t, err)
+
+	programs := map[string]command.Program{
+		"GroupBy":       command.GroupBy(1, 1, command.Count),
+		"ColumnStats":   command.ColumnStats(1),
+		"SelectColumns": command.SelectColumns("0"),
+		"Template":      tmpl,
+	}
+	for name, program := range programs {
+		t.Run(name, func(t *testing.T) {
+			cmd, input := command.Awk(program), concurrentInput(5000)
+			executeConcurrently(t, cmd, input, sequentialOutput(t, cmd, input))
+		})
+	}
+}
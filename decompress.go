@@ -0,0 +1,104 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Decompressor makes the command decompress the file operands named with
+// Extension, or whose content starts with Magic. Pass it as a parameter to
+// support a format such as zstd without this package importing it; gzip is
+// built in. Decompressors given as parameters are tried before gzip.
+type Decompressor struct {
+	// Extension is the file name suffix of the format, e.g. ".zst"
+	Extension string
+
+	// Magic are the bytes the format's content starts with
+	Magic []byte
+
+	// NewReader returns a reader of the data decompressed from r. If it also
+	// implements io.Closer it is closed once the file is read; r is not.
+	NewReader func(r io.Reader) (io.Reader, error)
+}
+
+func (d Decompressor) Configure(flags *flags) {
+	flags.Decompressors = append(flags.Decompressors, d)
+}
+
+// gzipDecompressor is the built-in Decompressor of .gz files
+var gzipDecompressor = Decompressor{
+	Extension: ".gz",
+	Magic:     []byte{0x1f, 0x8b},
+	NewReader: func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+}
+
+// decompress returns the content of the named file read from r, decompressed
+// if a Decompressor recognizes its name or, unless sniffing is off, its first
+// bytes. Decompression errors name the file and the offset in it.
+func (e *engine) decompress(name string, r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	d, ok := e.decompressor(name, br)
+	if !ok {
+		return br, nil
+	}
+	var offset int64
+	compressed := countingReader{r: br, n: &offset}
+	dr, err := d.NewReader(compressed)
+	if err != nil {
+		return nil, corrupt(name, offset, err)
+	}
+	return decompressed{r: dr, name: name, offset: &offset}, nil
+}
+
+// decompressor finds the Decompressor of the named file, if any
+func (e *engine) decompressor(name string, br *bufio.Reader) (Decompressor, bool) {
+	decompressors := append(e.decompressors[:len(e.decompressors):len(e.decompressors)], gzipDecompressor)
+	for _, d := range decompressors {
+		if d.Extension != "" && strings.HasSuffix(name, d.Extension) {
+			return d, true
+		}
+	}
+	if e.noSniff {
+		return Decompressor{}, false
+	}
+	for _, d := range decompressors {
+		if len(d.Magic) == 0 {
+			continue
+		}
+		if magic, _ := br.Peek(len(d.Magic)); bytes.Equal(magic, d.Magic) {
+			return d, true
+		}
+	}
+	return Decompressor{}, false
+}
+
+// decompressed reads decompressed data, reporting errors with their position
+type decompressed struct {
+	r      io.Reader
+	name   string
+	offset *int64
+}
+
+func (d decompressed) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if err != nil && err != io.EOF {
+		err = corrupt(d.name, *d.offset, err)
+	}
+	return n, err
+}
+
+func (d decompressed) Close() error {
+	if c, ok := d.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+// corrupt reports a decompression error in the named file
+func corrupt(name string, offset int64, err error) error {
+	return fmt.Errorf("%s: invalid compressed data near offset %d: %w", name, offset, err)
+}
@@ -0,0 +1,102 @@
+package command_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// gzipped returns text compressed with gzip
+func gzipped(t *testing.T, text string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write([]byte(text))
+	assertion.NoError(t, err)
+	assertion.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestAwk_Gzip_Extension(t *testing.T) {
+	path := writeBytes(t, "access.log.gz", gzipped(t, "a\nb\n"))
+	plain := writeFile(t, "plain.log", "c")
+
+	var stats command.Stats
+	result := run.Command(command.Awk(LineNumberProgram{}, path, plain, command.StatsRecipient(&stats))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: a", "2: b", "3: c"})
+	assertion.Equal(t, fmt.Sprint(stats.Files),
+		fmt.Sprintf("[{%s 2} {%s 1}]", path, plain), "files keep their on-disk names")
+}
+
+func TestAwk_Gzip_Sniffed(t *testing.T) {
+	path := writeBytes(t, "access.log.1", gzipped(t, "x y\n"))
+
+	result := run.Command(command.Awk(command.SimpleProgram{}, path)).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"x y"})
+}
+
+func TestAwk_Gzip_NoSniff(t *testing.T) {
+	content := append([]byte{0x1f, 0x8b}, "raw\n"...)
+	path := writeBytes(t, "magic.bin", content)
+
+	result := run.Command(command.Awk(command.SimpleProgram{}, path, command.NoSniff(true))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"\x1f\x8braw"})
+
+	result = run.Command(command.Awk(command.SimpleProgram{}, path)).Run()
+	assertion.ErrorContains(t, result.Err, path+": invalid compressed data")
+}
+
+func TestAwk_Gzip_Corrupt(t *testing.T) {
+	content := gzipped(t, strings.Repeat("line of text\n", 1000))
+	content[len(content)/2] ^= 0xff
+	path := writeBytes(t, "broken.gz", content)
+
+	result := run.Command(command.Awk(command.SimpleProgram{}, path)).Run()
+
+	assertion.ErrorContains(t, result.Err, path+": invalid compressed data near offset")
+}
+
+// reverseReader reverses each line of its input, as a toy compression format
+func reverseReader(r io.Reader) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	for i, line := range lines {
+		runes := []rune(line)
+		for a, b := 0, len(runes)-1; a < b; a, b = a+1, b-1 {
+			runes[a], runes[b] = runes[b], runes[a]
+		}
+		lines[i] = string(runes)
+	}
+	return strings.NewReader(strings.Join(lines, "\n") + "\n"), nil
+}
+
+func TestAwk_Decompressor(t *testing.T) {
+	path := writeFile(t, "data.rev", "cba", "fed")
+	rev := command.Decompressor{Extension: ".rev", NewReader: reverseReader}
+
+	result := run.Command(command.Awk(command.SimpleProgram{}, path, rev)).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"abc", "def"})
+}
+
+func TestAwkE_Decompressor_Invalid(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.Decompressor{Extension: ".zst"})
+	assertion.ErrorContains(t, err, `invalid Decompressor ".zst"`)
+}
@@ -0,0 +1,71 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorfFails makes a run in which the Program called ctx.Errorf fail once it
+// is over, with End run as usual, instead of only reporting the errors on
+// stderr
+type ErrorfFails bool
+
+func (f ErrorfFails) Configure(flags *flags) { flags.ErrorfFails = f }
+
+// Warnf writes a warning about the current record to the run's stderr, like
+// "awk: data.csv: line 12: warning: no price". The input name is left out
+// for stdin, and the line, the record's FNR, in Begin.
+func (c *Context) Warnf(format string, args ...any) {
+	c.diagnose("warning: ", fmt.Errorf(format, args...))
+}
+
+// Errorf writes an error about the current record to the run's stderr, like
+// Warnf without "warning: ", and returns it as a *RuntimeError, to return or
+// abort with. The run goes on; with ErrorfFails it fails once it is over.
+func (c *Context) Errorf(format string, args ...any) error {
+	err := &RuntimeError{NR: c.NR, Name: c.Filename, Err: fmt.Errorf(format, args...)}
+	c.diagnose("", err.Err)
+	if c.errorfFails {
+		if c.mu != nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+		}
+		c.errs = append(c.errs, err)
+	}
+	return err
+}
+
+// diagnose writes a message about the current record to stderr, if any
+func (c *Context) diagnose(kind string, err error) {
+	if c.stderr == nil {
+		return
+	}
+	prefix := "awk: "
+	if c.stage != "" {
+		prefix += c.stage + ": "
+	}
+	if c.Filename != "" {
+		prefix += c.Filename + ": "
+	}
+	if c.FNR > 0 {
+		prefix += fmt.Sprintf("line %d: ", c.FNR)
+	}
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	fmt.Fprintf(c.stderr, "%s%s%v\n", prefix, kind, err)
+}
+
+// failedErrorf joins err with the errors the Program reported with Errorf,
+// so the run fails once it is over with ErrorfFails
+func (e *engine) failedErrorf(err error) error {
+	if len(e.ctx.errs) == 0 {
+		return err
+	}
+	errs := []error{err}
+	for _, re := range e.ctx.errs {
+		errs = append(errs, e.errorf("%w", re))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package command_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// PriceCheckProgram warns about records without a price and reports an
+// error for negative prices, printing the other records
+type PriceCheckProgram struct {
+	command.SimpleProgram
+}
+
+func (p PriceCheckProgram) Begin(ctx *command.Context) error {
+	ctx.Warnf("checking prices")
+	return nil
+}
+
+func (p PriceCheckProgram) Action(ctx *command.Context) (string, bool) {
+	price, ok := ctx.FieldFloat(2)
+	switch {
+	case !ok:
+		ctx.Warnf("no price for %s", ctx.Field(1))
+		return "", false
+	case price < 0:
+		_ = ctx.Errorf("negative price %v", price)
+		return "", false
+	}
+	return ctx.Field(0), true
+}
+
+func TestContext_Warnf_Errorf(t *testing.T) {
+	result := run.Command(command.Awk(PriceCheckProgram{})).
+		WithStdinLines("apple 1", "pear", "plum -2", "fig 3").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"apple 1", "fig 3"})
+	assertion.Lines(t, result.Stderr, []string{
+		"awk: warning: checking prices",
+		"awk: line 2: warning: no price for pear",
+		"awk: line 3: negative price -2",
+	})
+}
+
+func TestContext_Warnf_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "a 1", "b")
+	second := writeFile(t, "second.txt", "c")
+	result := run.Command(command.Awk(PriceCheckProgram{}, first, second)).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stderr, []string{
+		"awk: warning: checking prices",
+		"awk: " + first + ": line 2: warning: no price for b",
+		"awk: " + second + ": line 1: warning: no price for c",
+	})
+}
+
+func TestContext_Errorf_ErrorfFails(t *testing.T) {
+	result := run.Command(command.Awk(PriceCheckProgram{}, command.ErrorfFails(true))).
+		WithStdinLines("plum -2", "fig 3", "kiwi -1").Run()
+
+	var re *command.RuntimeError
+	assertion.True(t, errors.As(result.Err, &re), "the run fails with the RuntimeErrors")
+	assertion.ErrorContains(t, result.Err, "record 1: negative price -2")
+	assertion.ErrorContains(t, result.Err, "record 3: negative price -1")
+	assertion.Lines(t, result.Stdout, []string{"fig 3"})
+	assertion.Count(t, result.Stderr, 3)
+}
+
+func TestContext_Warnf_Pipe(t *testing.T) {
+	result := run.Command(command.Pipe(command.SimpleProgram{}, PriceCheckProgram{})).
+		WithStdinLines("pear").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stderr, []string{
+		"awk: stage 2: warning: checking prices",
+		"awk: stage 2: line 1: warning: no price for pear",
+	})
+}
+
+func TestContext_Warnf_OutsideRun(t *testing.T) {
+	ctx := &command.Context{NR: 1}
+	ctx.Warnf("ignored")
+	err := ctx.Errorf("bad %d", 7)
+	assertion.True(t, strings.HasSuffix(err.Error(), "bad 7"), "Errorf returns the error")
+}
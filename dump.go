@@ -0,0 +1,83 @@
+package command
+
+import (
+	"bufio"
+	"fmt"
+	"maps"
+	"os"
+	"reflect"
+	"slices"
+)
+
+type dumpVariables struct {
+	on   bool
+	path string
+}
+
+// DumpVariables writes every variable and its final value to the file at
+// path once End has run, like gawk's -d; an empty path writes them to stderr.
+// The built-in variables FILENAME, FNR, FS, NF, NR, OFMT, OFS, ORS, RLENGTH,
+// RS and RSTART are included, strings are quoted and maps and slices are
+// summarized by their number of elements.
+func DumpVariables(path string) dumpVariables { return dumpVariables{on: true, path: path} }
+
+func (d dumpVariables) Configure(flags *flags) { flags.DumpVariables = d }
+
+// dumpVariables writes the variables as sorted "name: value" lines, if
+// DumpVariables is set
+func (e *engine) dumpVariables() (err error) {
+	if !e.dump.on {
+		return nil
+	}
+	w := e.stderr
+	if e.dump.path != "" {
+		f, err := os.Create(e.dump.path)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+		w = f
+	}
+	if w == nil {
+		return nil
+	}
+
+	c := e.ctx
+	vars := make(map[string]any)
+	for _, name := range c.VarNames() {
+		vars[name] = c.Var(name)
+	}
+	maps.Copy(vars, map[string]any{
+		"FILENAME": e.name, "FNR": c.FNR, "FS": c.FS, "NF": c.NF, "NR": c.NR,
+		"OFMT": c.OFMT, "OFS": c.OFS, "ORS": c.ORS, "RLENGTH": c.RLength, "RS": c.RS,
+		"RSTART": c.RStart,
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, name := range slices.Sorted(maps.Keys(vars)) {
+		fmt.Fprintf(bw, "%s: %s\n", name, c.dumpValue(vars[name]))
+	}
+	return bw.Flush()
+}
+
+// dumpValue renders a variable for DumpVariables
+func (c *Context) dumpValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case *Array:
+		return fmt.Sprintf("array, %d elements", v.Len())
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Invalid:
+		return "uninitialized"
+	case reflect.Map, reflect.Slice, reflect.Array:
+		return fmt.Sprintf("array, %d elements", rv.Len())
+	default:
+		return c.format(v)
+	}
+}
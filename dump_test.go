@@ -0,0 +1,69 @@
+package command_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// TallyProgram counts the records per first field
+type TallyProgram struct {
+	command.SimpleProgram
+}
+
+func (p TallyProgram) Begin(ctx *command.Context) error {
+	ctx.SetVar("seen", map[string]int{})
+	ctx.SetVar("label", "tally")
+	return nil
+}
+
+func (p TallyProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.Var("seen").(map[string]int)[ctx.Field(1)]++
+	ctx.ArrayVar("letters").Incr(ctx.Field(2), 1)
+	ctx.AddVar("total", 1.5)
+	return "", false
+}
+
+func TestAwk_DumpVariables(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "vars.txt")
+	data := writeFile(t, "data.txt", "a x", "b y", "a z")
+	result := run.Command(command.Awk(TallyProgram{}, data, command.DumpVariables(path),
+		command.Variable{Name: "unset"})).Run()
+
+	assertion.NoError(t, result.Err)
+	dump, err := os.ReadFile(path)
+	assertion.NoError(t, err)
+	assertion.Lines(t, strings.Split(strings.TrimSuffix(string(dump), "\n"), "\n"), []string{
+		`FILENAME: "` + data + `"`,
+		`FNR: 3`,
+		`FS: " "`,
+		`NF: 2`,
+		`NR: 3`,
+		`OFMT: "%.6g"`,
+		`OFS: " "`,
+		`ORS: "\n"`,
+		`RLENGTH: 0`,
+		`RS: "\n"`,
+		`RSTART: 0`,
+		`label: "tally"`,
+		`letters: array, 3 elements`,
+		`seen: array, 2 elements`,
+		`total: 4.5`,
+		`unset: uninitialized`,
+	})
+}
+
+func TestAwk_DumpVariables_Stderr(t *testing.T) {
+	result := run.Command(command.Awk(TallyProgram{}, command.DumpVariables(""))).
+		WithStdinLines("a").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Empty(t, result.Stdout)
+	assertion.Contains(t, result.Stderr, `FILENAME: ""`)
+	assertion.Contains(t, result.Stderr, `total: 1.5`)
+}
@@ -0,0 +1,74 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// RowsProgram emits a row per field with EmitFields, then returns the record
+type RowsProgram struct {
+	command.SimpleProgram
+}
+
+func (p RowsProgram) Begin(ctx *command.Context) error {
+	return ctx.EmitFields("field", "value")
+}
+
+func (p RowsProgram) Action(ctx *command.Context) (string, bool) {
+	for i := 1; i <= ctx.NF; i++ {
+		_ = ctx.EmitFields(ctx.NR, i, ctx.Field(i))
+	}
+	return "end of " + ctx.Field(0), true
+}
+
+func (p RowsProgram) End(ctx *command.Context) (string, error) {
+	if err := ctx.EmitFields("records", ctx.NR); err != nil {
+		return "", err
+	}
+	return ctx.Print("average", 1.5), nil
+}
+
+func TestContext_EmitFields(t *testing.T) {
+	result := run.Command(command.Awk(RowsProgram{}, command.OutputFieldSeparator(","))).
+		WithStdinLines("a b", "c").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"field,value",
+		"1,1,a",
+		"1,2,b",
+		"end of a b",
+		"2,1,c",
+		"end of c",
+		"records,2",
+		"average,1.5",
+	})
+}
+
+func TestContext_EmitFields_ORS(t *testing.T) {
+	output := execute(t, RowsProgram{}, "a\n", command.OutputRecordSeparator(";"))
+	assertion.Equal(t, output, "field value;1 1 a;end of a;records 1;average 1.5;", "every record ends with ORS")
+}
+
+func TestContext_EmitFields_Pipe(t *testing.T) {
+	result := run.Command(command.Pipe(RowsProgram{}, LineNumberProgram{})).
+		WithStdinLines("x").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"1: field value", "2: 1 1 x", "3: end of x", "4: records 1", "5: average 1.5",
+	})
+}
+
+func TestContext_EmitFields_OutsideRun(t *testing.T) {
+	ctx := &command.Context{}
+	assertion.Error(t, ctx.EmitFields("a"))
+}
+
+func TestAwk_OutputRecordSeparator(t *testing.T) {
+	output := execute(t, command.SimpleProgram{}, "a\nb\n", command.OutputRecordSeparator("\r\n"))
+	assertion.Equal(t, output, "a\r\nb\r\n", "pass-through output uses ORS too")
+}
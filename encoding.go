@@ -0,0 +1,47 @@
+package command
+
+import (
+	"io"
+
+	"github.com/yupsh/awk/internal/charset"
+)
+
+// InputDecoder converts the input to UTF-8 before it is split into records,
+// for encodings InputEncoding does not know (e.g. from golang.org/x/text)
+type InputDecoder func(r io.Reader) io.Reader
+
+// OutputEncoder converts the UTF-8 output, for encodings OutputEncoding does
+// not know. A writer that is an io.Closer is closed once the run is over, to
+// flush what it holds back; it must not close the writer it was given.
+type OutputEncoder func(w io.Writer) io.Writer
+
+func (d InputDecoder) Configure(flags *flags)  { flags.InputDecoder = d }
+func (e OutputEncoder) Configure(flags *flags) { flags.OutputEncoder = e }
+
+// decoder returns the function converting the input to UTF-8, or nil
+func decoder(f flags) InputDecoder {
+	if f.InputDecoder != nil {
+		return f.InputDecoder
+	}
+	if c, _ := charset.Lookup(string(f.InputEncoding)); c != nil {
+		return c.NewReader
+	}
+	return nil
+}
+
+// encode returns w converting the UTF-8 output to the output encoding, and
+// the function flushing the encoder once the run is over
+func encode(w io.Writer, f flags) (io.Writer, func() error) {
+	var encoded io.Writer
+	if f.OutputEncoder != nil {
+		encoded = f.OutputEncoder(w)
+	} else if c, _ := charset.Lookup(string(f.OutputEncoding)); c != nil {
+		encoded = c.NewWriter(w)
+	} else {
+		return w, func() error { return nil }
+	}
+	if c, ok := encoded.(io.Closer); ok {
+		return encoded, c.Close
+	}
+	return encoded, func() error { return nil }
+}
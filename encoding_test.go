@@ -0,0 +1,93 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	command "github.com/yupsh/awk"
+)
+
+// LengthProgram prints every record with its length in characters
+type LengthProgram struct {
+	command.SimpleProgram
+}
+
+func (p LengthProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Print(ctx.Field(0), ctx.Length(ctx.Field(0))), true
+}
+
+func TestAwk_InputEncoding(t *testing.T) {
+	output := execute(t, LengthProgram{}, "caf\xe9\n", command.InputEncoding("ISO-8859-1"))
+	assertion.Equal(t, output, "café 4\n", "decoded to UTF-8 before splitting")
+
+	output = execute(t, command.SimpleProgram{}, "caf\xe9\r\n", command.InputEncoding("latin1"))
+	assertion.Equal(t, output, "café\n", "pass-through output is decoded too")
+}
+
+func TestAwk_OutputEncoding(t *testing.T) {
+	output := execute(t, UppercaseProgram{}, "café “quoted”\n", command.OutputEncoding("windows-1252"))
+	assertion.Equal(t, output, "CAF\xc9 \x93QUOTED\x94\n", "encoded from UTF-8")
+
+	output = execute(t, command.SimpleProgram{}, "\x93caf\xe9\x94\n",
+		command.InputEncoding("cp1252"), command.OutputEncoding("cp1252"))
+	assertion.Equal(t, output, "\x93caf\xe9\x94\n", "round trip")
+}
+
+func TestAwk_OutputEncoding_TruncatedRune(t *testing.T) {
+	output := execute(t, command.SimpleProgram{}, "a\ncaf\xe6\x97", command.OutputEncoding("latin1"), command.PreserveTerminators())
+	assertion.Equal(t, output, "a\ncaf?", "an output ending mid-character ends with '?'")
+}
+
+// holdingWriter writes nothing until it is closed
+type holdingWriter struct {
+	w    io.Writer
+	held bytes.Buffer
+}
+
+func (h *holdingWriter) Write(b []byte) (int, error) { return h.held.Write(b) }
+
+func (h *holdingWriter) Close() error {
+	_, err := h.held.WriteTo(h.w)
+	return err
+}
+
+func TestAwk_OutputEncoder_Close(t *testing.T) {
+	output := execute(t, command.SimpleProgram{}, "a\nb\n", command.OutputEncoder(func(w io.Writer) io.Writer {
+		return &holdingWriter{w: w}
+	}))
+	assertion.Equal(t, output, "a\nb\n", "the encoder is closed at the end of the run")
+}
+
+func TestAwk_InputDecoder(t *testing.T) {
+	upper := func(r io.Reader) io.Reader {
+		data, _ := io.ReadAll(r)
+		return bytes.NewReader(bytes.ToUpper(data))
+	}
+	output := execute(t, command.SimpleProgram{}, "abc\n", command.InputDecoder(upper))
+	assertion.Equal(t, output, "ABC\n", "decoder hook")
+
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(command.SimpleProgram{}, command.OutputEncoder(func(w io.Writer) io.Writer {
+		return upperWriter{w: w}
+	})).Executor()(context.Background(), strings.NewReader("a\nb\n"), &stdout, &stderr)
+	assertion.NoError(t, err)
+	assertion.Equal(t, stdout.String(), "A\nB\n", "encoder hook")
+}
+
+// upperWriter upper-cases what it writes
+type upperWriter struct{ w io.Writer }
+
+func (u upperWriter) Write(b []byte) (int, error) {
+	return u.w.Write(bytes.ToUpper(b))
+}
+
+func TestAwkE_UnknownEncoding(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.InputEncoding("EBCDIC"), command.OutputEncoding("koi9"))
+
+	assertion.ErrorContains(t, err, `unknown InputEncoding "EBCDIC"`)
+	assertion.ErrorContains(t, err, `unknown OutputEncoding "koi9"`)
+}
@@ -0,0 +1,627 @@
+package command
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	gloo "github.com/gloo-foo/framework"
+)
+
+// engine drives one Program through BEGIN, the records and END.
+// Every execution gets its own engine, so no run state lives on the command.
+type engine struct {
+	program Program
+	ctx     *Context
+
+	// stage names the Program in error messages ("" outside a Pipe)
+	stage string
+
+	// name is the name of the input being read ("" for stdin)
+	name string
+
+	// emit receives every record the Program outputs with its terminator
+	emit func(record, terminator string) error
+
+	// preserve terminates the records Action outputs with the input record's
+	// RT instead of a newline
+	preserve bool
+
+	// keep includes RT in $0, and asRead is $0 as it was read
+	keep   bool
+	asRead string
+
+	// out is the writer given to ActionWriter Programs
+	out io.Writer
+
+	// copyTo is set when the Program is a pass-through and its records can be
+	// copied verbatim to this writer
+	copyTo io.Writer
+
+	// trace logs the run for debugging (nil when tracing is off)
+	trace *tracer
+
+	// stats are the run's counters, stored in statsTo when the run finishes
+	stats   Stats
+	statsTo *Stats
+
+	// pass is the current pass over the input, of passes (see MultiPass).
+	// suppress drops the output of the passes before the last one, unless
+	// emitAll. Inputs that cannot be opened again are spooled.
+	pass, passes int
+	suppress     bool
+	emitAll      bool
+	startNR      int64
+	spools       map[int]*spool
+	spoolLimit   int64
+
+	// tail keeps the last records to process once the input is read
+	// (nil unless TailRecords is set)
+	tail *tailRing
+
+	// split tokenizes the input into records instead of RS, if set
+	split bufio.SplitFunc
+
+	// readBufferSize is the initial size of the input buffer (0 for the
+	// default), which grows up to maxRecordSize to hold a record
+	readBufferSize int
+	maxRecordSize  int
+
+	// decompressors are tried on file operands before gzip; noSniff only
+	// recognizes compressed files by their extension
+	decompressors []Decompressor
+	noSniff       bool
+
+	// decode converts the input to UTF-8 (nil when it already is)
+	decode InputDecoder
+
+	// calling is the Program method being called, for PanicErrors ("" when
+	// the engine runs its own code); noRecover lets panics go on
+	calling   string
+	noRecover bool
+
+	// outputs are the destinations of ctx.EmitTo
+	outputs *outputs
+
+	// variables are the initial variables, restored between files with ResetPerFile
+	variables    map[string]any
+	resetPerFile bool
+
+	// fileErrors are the input files that could not be opened, reported on
+	// stderr unless fatalFileErrors
+	stderr          io.Writer
+	fileErrors      []error
+	fatalFileErrors bool
+
+	// glob expands the patterns among the file operands
+	glob, nullGlob bool
+
+	// dump is where DumpVariables writes the variables after END
+	dump dumpVariables
+
+	// maxOutput is MaxOutputBytes (0 for no limit), and written the bytes
+	// output so far, which unlike the statistics is not reset by passes
+	maxOutput, written int64
+
+	// observer receives the events of the run (nil for none)
+	observer observe
+
+	// seen is the current record as read, for Lookback
+	seen *Record
+
+	// downstream is the engine of the next stage of a Pipe, which stops the
+	// inputs when its Program calls Exit (nil outside a Pipe)
+	downstream *engine
+}
+
+// newEngine creates the engine running program with the given flags. Records
+// go to emit and traces to stderr; stage names the Program inside a Pipe.
+func newEngine(ctx context.Context, program Program, f flags, emit func(string, string) error, stderr io.Writer, stage string) *engine {
+	awkCtx := &Context{
+		NR:        int64(f.StartNR),
+		Pass:      1,
+		FS:        string(f.FieldSeparator),
+		OFS:       string(f.OutputFieldSeparator),
+		OFMT:      string(f.OutputFormat),
+		BytesMode: bool(f.BytesMode),
+		RS:        string(f.RecordSeparator),
+		ORS:       string(f.OutputRecordSeparator),
+		Variables: make(map[string]any),
+		ctx:       ctx,
+		environ:   environment(f),
+		baseDir:   string(f.BaseDir),
+		started:   time.Now(),
+
+		regexpCacheSize: int(f.RegexpCacheSize),
+		lookback:        newLookback(f.Lookback),
+		stderr:          stderr,
+		stage:           stage,
+		errorfFails:     bool(f.ErrorfFails),
+	}
+
+	// Copy initial variables from flags
+	maps.Copy(awkCtx.Variables, f.Variables)
+
+	// Only pay for locking when Programs share variables between goroutines
+	if f.SharedVariables {
+		awkCtx.mu = &sync.Mutex{}
+	}
+
+	e := &engine{
+		program:         program,
+		ctx:             awkCtx,
+		stage:           stage,
+		emit:            emit,
+		preserve:        bool(f.PreserveTerminators),
+		keep:            bool(f.KeepTerminator),
+		statsTo:         f.StatsRecipient,
+		passes:          1,
+		emitAll:         bool(f.EmitAllPasses),
+		startNR:         int64(f.StartNR),
+		spools:          make(map[int]*spool),
+		spoolLimit:      int64(cmp.Or(f.SpoolLimit, defaultSpoolLimit)),
+		tail:            newTailRing(f.TailRecords),
+		split:           bufio.SplitFunc(f.RecordSplitter),
+		readBufferSize:  int(f.ReadBufferSize),
+		maxRecordSize:   int(f.MaxRecordSize),
+		decompressors:   f.Decompressors,
+		noSniff:         bool(f.NoSniff),
+		decode:          decoder(f),
+		outputs:         newOutputs(f),
+		noRecover:       bool(f.NoRecover),
+		variables:       f.Variables,
+		resetPerFile:    bool(f.ResetPerFile),
+		stderr:          stderr,
+		fatalFileErrors: bool(f.FatalFileErrors),
+		glob:            bool(f.Glob),
+		nullGlob:        bool(f.NullGlob),
+		dump:            f.DumpVariables,
+		maxOutput:       int64(f.MaxOutputBytes),
+		observer:        f.Observer,
+	}
+	if mp, ok := program.(MultiPass); ok {
+		e.passes = max(mp.Passes(), 1)
+	}
+	e.trace = newTracer(stderr, f.TraceEvery, stage)
+	awkCtx.output = e.output
+	awkCtx.emitTo = e.emitTo
+	awkCtx.stats = &e.stats
+	return e
+}
+
+// begin calls the Program's Begin
+func (e *engine) begin() (err error) {
+	e.observe("OnStart", func(o Observer) { o.OnStart(RunInfo{Stage: e.stage, Started: e.ctx.started}) })
+	defer e.catch(&err)
+	e.calling = "Begin"
+	err = e.program.Begin(e.ctx)
+	e.calling = ""
+	e.trace.begin(err)
+	if err != nil {
+		return e.errorf("BEGIN: %w", err)
+	}
+	if err := e.ctx.aborted(); err != nil {
+		return e.errorf("BEGIN: %w", err)
+	}
+	return nil
+}
+
+// scanInputs feeds the command's file operands or Sources to the Program one
+// after another, or stdin when there are none. "-" names stdin.
+func (e *engine) scanInputs(inputs gloo.Inputs[gloo.File, flags], stdin io.Reader) error {
+	err := e.scanPasses(inputs, stdin)
+	if errors.Is(err, errExit) || e.ctx.exited() != nil || e.ctx.Context().Err() != nil {
+		e.stats.StoppedEarly = true
+	}
+	if errors.Is(err, errExit) {
+		return nil
+	}
+	return err
+}
+
+// scanPasses reads the inputs once for every pass, until the Program calls
+// Exit
+func (e *engine) scanPasses(inputs gloo.Inputs[gloo.File, flags], stdin io.Reader) error {
+	if e.ctx.exited() != nil {
+		return nil
+	}
+	mp, multi := e.program.(MultiPass)
+	for e.pass = 1; e.pass <= e.passes; e.pass++ {
+		if multi {
+			if err := e.beginPass(mp); err != nil {
+				return err
+			}
+		}
+		if err := e.scanAll(inputs, stdin); err != nil {
+			return err
+		}
+		if err := e.replay(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanAll reads every input of the command
+func (e *engine) scanAll(inputs gloo.Inputs[gloo.File, flags], stdin io.Reader) error {
+	src := inputs.Flags.RecordSource
+	if ch := inputs.Flags.RecordChannel; ch != nil {
+		src = channelSource{ctx: e.ctx.Context(), ch: ch}
+	}
+	if src != nil {
+		if e.pass > 1 {
+			return errors.New("a RecordSource can only be read once")
+		}
+		defer e.startInput("")()
+		return e.read(src)
+	}
+	if sources := inputs.Flags.Sources; len(sources) > 0 {
+		for i, source := range sources {
+			if i > 0 {
+				e.nextFile()
+			}
+			if err := e.scan(source.Name, e.rewind(i, source.Reader)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if len(inputs.Positional) == 0 {
+		return e.scan("", e.rewind(-1, inputs.Reader(stdin)))
+	}
+	files, err := e.expand(inputs.Positional)
+	if err != nil {
+		return err
+	}
+	for i, file := range files {
+		if i > 0 {
+			e.nextFile()
+		}
+		in := stdin
+		if file == "-" {
+			in = e.rewind(i, stdin)
+		}
+		if err := e.scanFile(string(file), in); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanFile feeds the records of the named file to the Program
+func (e *engine) scanFile(name string, stdin io.Reader) error {
+	if name == "-" {
+		return e.scan(name, stdin)
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		return e.fileError(&FileError{Name: name, Err: err})
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.IsDir() {
+		return e.fileError(&FileError{Name: name, Err: errIsDir})
+	}
+
+	r, err := e.decompress(name, f)
+	if err != nil {
+		return err
+	}
+	if c, ok := r.(io.Closer); ok {
+		defer c.Close()
+	}
+	return e.scan(name, r)
+}
+
+// nextFile runs between two input files
+func (e *engine) nextFile() {
+	if !e.resetPerFile {
+		return
+	}
+	e.ctx.Variables = maps.Clone(e.variables)
+	if e.ctx.Variables == nil {
+		e.ctx.Variables = make(map[string]any)
+	}
+	e.ctx.states = nil
+	if r, ok := e.program.(Resetter); ok {
+		r.Reset()
+	}
+}
+
+// startInput starts reading the named input; the function it returns
+// records the input's statistics once it is read
+func (e *engine) startInput(name string) func() {
+	e.name = name
+	e.ctx.Filename, e.ctx.FNR = name, 0
+	startNR := e.ctx.NR
+	e.observe("OnFileStart", func(o Observer) { o.OnFileStart(name) })
+	return func() {
+		records := e.ctx.NR - startNR
+		e.stats.Files = append(e.stats.Files, FileStats{Name: name, Records: records})
+		e.observe("OnFileEnd", func(o Observer) { o.OnFileEnd(name, records) })
+	}
+}
+
+// scan feeds every record of the named input to the Program
+func (e *engine) scan(name string, r io.Reader) error {
+	defer e.startInput(name)()
+
+	r = countingReader{r: r, n: &e.stats.BytesRead}
+	if e.decode != nil {
+		r = e.decode(r)
+	}
+	if e.copyTo != nil && e.maxOutput == 0 && e.observer.observer == nil && e.passes == 1 && e.trace == nil && e.tail == nil && e.split == nil && e.ctx.RS == "\n" && (e.preserve || e.keep || e.ctx.ORS == "\n") {
+		return e.copyRecords(r, e.copyTo)
+	}
+
+	splitter, err := newRecordSplitter(e.ctx.RS)
+	if err != nil {
+		return err
+	}
+	size := cmp.Or(e.readBufferSize, defaultReadBufferSize)
+	limit := max(size, e.maxRecordSize)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, size), limit)
+	if e.split != nil {
+		// The caller's tokenizer does not report terminators
+		splitter.rt = ""
+		scanner.Split(e.split)
+	} else {
+		scanner.Split(splitter.split)
+	}
+	return e.read(&textSource{scanner: scanner, splitter: splitter, limit: limit})
+}
+
+// read feeds every record of src to the Program, stopping when the run is
+// cancelled
+func (e *engine) read(src RecordSource) error {
+	terminated, _ := src.(interface{ RT() string })
+	// pending is the error Getline met, returned once the record is processed
+	var pending error
+	if e.tail == nil {
+		e.ctx.getline = func() (string, bool) {
+			fields, raw, err := src.Next()
+			if err == nil {
+				err = e.ctx.Context().Err()
+			}
+			if err != nil {
+				if err != io.EOF {
+					pending = e.readError(err)
+				}
+				return "", false
+			}
+			rt := ""
+			if terminated != nil {
+				rt = terminated.RT()
+			}
+			return e.getline(fields, raw, rt), true
+		}
+		defer func() { e.ctx.getline = nil }()
+	}
+	for {
+		fields, raw, err := src.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return e.readError(err)
+		}
+		if err := e.ctx.Context().Err(); err != nil {
+			return err
+		}
+		if e.downstream != nil && e.downstream.ctx.exited() != nil {
+			return errExit
+		}
+		e.ctx.RT = ""
+		if terminated != nil {
+			e.ctx.RT = terminated.RT()
+		}
+		if e.tail != nil {
+			e.ctx.NR++
+			e.ctx.FNR++
+			e.stats.Records++
+			e.observeRecord()
+			e.tail.push(tailRecord{fields: fields, text: raw, rt: e.ctx.RT, nr: e.ctx.NR, fnr: e.ctx.FNR, name: e.name})
+			continue
+		}
+		if err := e.record(fields, raw); err != nil {
+			return err
+		}
+		if pending != nil {
+			return pending
+		}
+	}
+}
+
+// readError returns the error reading the record after NR
+func (e *engine) readError(err error) error {
+	if errors.Is(err, bufio.ErrTooLong) {
+		return fmt.Errorf("record %d is %w", e.ctx.NR+1, err)
+	}
+	return err
+}
+
+// input processes a record emitted by another engine, terminated by
+// terminator; once the Program has called Exit, records are dropped
+func (e *engine) input(record, terminator string) error {
+	if e.ctx.exited() != nil {
+		return nil
+	}
+	e.ctx.RT = terminator
+	return e.record(nil, record)
+}
+
+// record processes a single input record, split into fields unless they
+// are given
+func (e *engine) record(fields []string, line string) error {
+	e.ctx.NR++
+	e.ctx.FNR++
+	e.stats.Records++
+	e.observeRecord()
+	return e.process(fields, line)
+}
+
+// process runs the Program over the record numbered NR
+func (e *engine) process(fields []string, line string) (err error) {
+	defer e.catch(&err)
+	e.load(fields, line)
+	if e.ctx.lookback != nil {
+		// The last record the Program has seen, which Getline may change
+		defer func() { e.ctx.lookback.push(e.seen) }()
+	}
+
+	var (
+		output        string
+		emit, written bool
+	)
+	e.calling = "Condition"
+	cond := e.program.Condition(e.ctx)
+	if !cond {
+		e.stats.Skipped++
+	} else if aw, ok := e.program.(ActionWriter); ok {
+		e.calling = "ActionWriter"
+		written, err = e.writeAction(aw)
+	} else {
+		e.calling = "Action"
+		output, emit = e.program.Action(e.ctx)
+	}
+	e.calling = ""
+	e.trace.record(e.ctx, cond, emit || written)
+	if err != nil {
+		return err
+	}
+
+	// An aborted record's output is dropped
+	if err := e.ctx.aborted(); err != nil {
+		return e.recordError(err)
+	}
+	if emit {
+		if err := e.output(output, e.terminator(output)); err != nil {
+			return err
+		}
+	}
+	if e.ctx.exited() != nil {
+		return errExit
+	}
+	return nil
+}
+
+// load makes a record the current one, keeping a copy of it for Lookback
+func (e *engine) load(fields []string, line string) {
+	e.ctx.setRecord(fields, line)
+	if e.keep {
+		e.ctx.Fields[0] += e.ctx.RT
+		e.asRead = e.ctx.Fields[0]
+	}
+	if e.ctx.lookback != nil {
+		e.seen = e.ctx.Snapshot()
+	}
+}
+
+// terminator returns the terminator of a record output by Action
+func (e *engine) terminator(output string) string {
+	if e.keep {
+		// Output that carries the record's terminator, or is the unterminated
+		// last record as it was read, is written as it is
+		rt := e.ctx.RT
+		if (rt != "" && strings.HasSuffix(output, rt)) || (rt == "" && output == e.asRead) {
+			return ""
+		}
+	}
+	if e.preserve {
+		return e.ctx.RT
+	}
+	return e.ctx.ORS
+}
+
+// end calls the Program's End and emits its output, if any. The run fails
+// if an input file could not be opened.
+func (e *engine) end() (err error) {
+	defer func() { err = e.failedErrorf(e.failedFiles(err)) }()
+	defer e.catch(&err)
+	e.calling = "End"
+	output, err := e.program.End(e.ctx)
+	e.calling = ""
+	e.trace.end(e.ctx, output != "", err)
+	if err == nil {
+		err = e.ctx.aborted()
+	}
+	if err != nil {
+		return e.errorf("END: %w", err)
+	}
+	if output != "" {
+		if err := e.output(output, e.ctx.ORS); err != nil {
+			return err
+		}
+	}
+	if err := errors.Join(e.dumpVariables(), e.outputs.close(), e.ctx.closeReaders()); err != nil {
+		return err
+	}
+	return e.exitError()
+}
+
+// output emits a record produced by the Program
+func (e *engine) output(record, terminator string) error {
+	if e.suppress {
+		return nil
+	}
+	if err := e.outputLimit(len(record) + len(terminator)); err != nil {
+		return e.recordError(err)
+	}
+	e.observeEmit()
+	e.wrote(len(record) + len(terminator))
+	return e.emit(record, terminator)
+}
+
+// finish stores the run's statistics with the recipient, if any, closes the
+// files the Program wrote or read, removes the spooled inputs and tells the
+// Observer the run ended with err. It runs however the run ended.
+func (e *engine) finish(err error) {
+	_ = e.outputs.close()
+	_ = e.ctx.closeReaders()
+	for _, s := range e.spools {
+		s.close()
+	}
+	if e.statsTo != nil {
+		*e.statsTo = e.ctx.Stats()
+	}
+	e.observe("OnFinish", func(o Observer) { o.OnFinish(e.ctx.Stats(), err) })
+}
+
+// errorf formats an error raised by the Program, prefixed with its stage
+func (e *engine) errorf(format string, args ...any) error {
+	err := fmt.Errorf(format, args...)
+	if e.stage != "" {
+		return fmt.Errorf("%s: %w", e.stage, err)
+	}
+	return err
+}
+
+// buffer returns w buffered with the given size, and the function flushing
+// the buffer once the run is over. Zero leaves w unbuffered.
+func buffer(w io.Writer, size WriteBufferSize) (io.Writer, func() error) {
+	if size <= 0 {
+		return w, func() error { return nil }
+	}
+	bw := bufio.NewWriterSize(w, int(size))
+	return bw, bw.Flush
+}
+
+// recordError reports an error raised by the Program for the current record
+func (e *engine) recordError(err error) error {
+	return e.errorf("%w", &RuntimeError{NR: e.ctx.NR, Name: e.name, Err: err})
+}
+
+// writeLines returns an emit function writing each record and its terminator to w
+func writeLines(w io.Writer) func(string, string) error {
+	return func(record, terminator string) error {
+		_, err := io.WriteString(w, record+terminator)
+		return err
+	}
+}
@@ -0,0 +1,109 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// FatalFileErrors fails the run as soon as an input file cannot be opened,
+// instead of reporting it on stderr and going on with the other files
+type FatalFileErrors bool
+
+func (f FatalFileErrors) Configure(flags *flags) { flags.FatalFileErrors = f }
+
+// errIsDir is the error of a file operand that is a directory
+var errIsDir = errors.New("is a directory")
+
+// FileError is the error of an input file that could not be opened
+type FileError struct {
+	// Name is the file operand
+	Name string
+
+	// Err is the error opening it
+	Err error
+}
+
+func (f *FileError) Error() string {
+	cause := f.Err
+	var pe *fs.PathError
+	if errors.As(cause, &pe) {
+		cause = pe.Err
+	}
+	return fmt.Sprintf("awk: can't open file %s: %v", f.Name, cause)
+}
+
+func (f *FileError) Unwrap() error { return f.Err }
+
+// RuntimeError is the error the Program raised for a record, by returning it
+// from ActionWriter or aborting the run with it
+type RuntimeError struct {
+	// NR and Name identify the record being processed
+	NR   int64
+	Name string
+
+	// Err is the Program's error
+	Err error
+}
+
+// fileError reports the input file that could not be opened: it is returned
+// with FatalFileErrors, and otherwise written to stderr like awk does and kept
+// for the end of the run
+func (e *engine) fileError(fe *FileError) error {
+	if e.fatalFileErrors {
+		return fe
+	}
+	if e.pass > 1 {
+		// Reported by the first pass
+		return nil
+	}
+	if e.stderr != nil {
+		fmt.Fprintln(e.stderr, fe)
+	}
+	e.fileErrors = append(e.fileErrors, fe)
+	return nil
+}
+
+// failedFiles joins err with the errors of the input files that could not be
+// opened, so the run fails once it is over
+func (e *engine) failedFiles(err error) error {
+	if len(e.fileErrors) == 0 {
+		return err
+	}
+	return errors.Join(append([]error{err}, e.fileErrors...)...)
+}
+
+func (r *RuntimeError) Error() string {
+	if r.Name == "" {
+		return fmt.Sprintf("record %d: %v", r.NR, r.Err)
+	}
+	return fmt.Sprintf("record %d (%s): %v", r.NR, r.Name, r.Err)
+}
+
+func (r *RuntimeError) Unwrap() error { return r.Err }
+
+// ExitError ends a run with an exit status, like awk's exit statement: a run
+// whose Program called ctx.Exit with a non-zero code fails with it, and a
+// Program may also abort with it, or return it from Begin or End, to have the
+// run fail with ExitStatus Code right away
+type ExitError struct {
+	Code int
+}
+
+func (x *ExitError) Error() string {
+	return fmt.Sprintf("awk: exit status %d", x.Code)
+}
+
+// ExitStatus returns the exit status of a command failing with err, as awk
+// reports it: 0 for success, the Code of an ExitError and 2 for any other
+// error
+func ExitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var x *ExitError
+	if errors.As(err, &x) {
+		return x.Code
+	}
+	return 2
+}
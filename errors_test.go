@@ -0,0 +1,129 @@
+package command_test
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestFileError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nosuch.txt")
+	result := run.Command(command.Awk(command.SimpleProgram{}, path)).Run()
+
+	var fe *command.FileError
+	assertion.True(t, errors.As(result.Err, &fe), "the run fails with a FileError")
+	assertion.Equal(t, fe.Name, path, "file name")
+	assertion.True(t, errors.Is(result.Err, fs.ErrNotExist), "the open error is wrapped")
+	assertion.Equal(t, result.Err.Error(), "awk: can't open file "+path+": no such file or directory", "message")
+	assertion.Equal(t, command.ExitStatus(result.Err), 2, "exit status")
+}
+
+func TestFileError_Continue(t *testing.T) {
+	dir := t.TempDir()
+	first, last := writeFile(t, "first.txt", "a", "b"), writeFile(t, "last.txt", "c")
+	missing := filepath.Join(dir, "nosuch.txt")
+	result := run.Command(command.Awk(&CountingProgram{}, first, missing, dir, last)).Run()
+
+	assertion.Lines(t, result.Stdout, []string{"Total lines: 3"})
+	assertion.Lines(t, result.Stderr, []string{
+		"awk: can't open file " + missing + ": no such file or directory",
+		"awk: can't open file " + dir + ": is a directory",
+	})
+	var fe *command.FileError
+	assertion.True(t, errors.As(result.Err, &fe), "the run fails with the FileErrors")
+	assertion.True(t, errors.Is(result.Err, fs.ErrNotExist), "the open error is wrapped")
+	assertion.ErrorContains(t, result.Err, dir+": is a directory")
+}
+
+func TestFileError_PermissionDenied(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can read any file")
+	}
+	path := writeFile(t, "secret.txt", "x")
+	assertion.NoError(t, os.Chmod(path, 0))
+	result := run.Command(command.Awk(LineNumberProgram{}, path, writeFile(t, "data.txt", "y"))).Run()
+
+	assertion.Lines(t, result.Stdout, []string{"1: y"})
+	assertion.True(t, errors.Is(result.Err, fs.ErrPermission), "the open error is wrapped")
+}
+
+func TestFileError_Fatal(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "nosuch.txt")
+	result := run.Command(command.Awk(&CountingProgram{}, writeFile(t, "data.txt", "a"), missing, command.FatalFileErrors(true))).Run()
+
+	var fe *command.FileError
+	assertion.True(t, errors.As(result.Err, &fe), "the run fails with the FileError")
+	assertion.Empty(t, result.Stdout)
+	assertion.Empty(t, result.Stderr)
+}
+
+func TestFileError_ExitStatus(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "nosuch.txt")
+	result := run.Command(command.Awk(ExitProgram{}, missing)).Run()
+
+	var fe *command.FileError
+	assertion.True(t, errors.As(result.Err, &fe), "the FileError is kept")
+	assertion.Equal(t, command.ExitStatus(result.Err), 4, "the exit status of End is not masked")
+}
+
+func TestRuntimeError(t *testing.T) {
+	var ends int
+	path := writeFile(t, "data.txt", "a", "bad")
+	result := run.Command(command.Awk(AbortProgram{ends: &ends}, path)).Run()
+
+	var re *command.RuntimeError
+	assertion.True(t, errors.As(result.Err, &re), "the run fails with a RuntimeError")
+	assertion.Equal(t, re.NR, int64(2), "record number")
+	assertion.Equal(t, re.Name, path, "file name")
+	assertion.True(t, errors.Is(result.Err, errCorrupt), "the Program's error is wrapped")
+	assertion.Equal(t, command.ExitStatus(result.Err), 2, "exit status")
+}
+
+// ExitProgram ends the run with status 3 on the record "exit", or with
+// status 4 from End
+type ExitProgram struct {
+	command.SimpleProgram
+}
+
+func (p ExitProgram) Action(ctx *command.Context) (string, bool) {
+	if ctx.Field(0) == "exit" {
+		ctx.Abort(&command.ExitError{Code: 3})
+	}
+	return ctx.Field(0), true
+}
+
+func (p ExitProgram) End(ctx *command.Context) (string, error) {
+	return "", &command.ExitError{Code: 4}
+}
+
+func TestExitError(t *testing.T) {
+	result := run.Command(command.Awk(ExitProgram{})).WithStdinLines("a", "exit", "b").Run()
+
+	var x *command.ExitError
+	assertion.True(t, errors.As(result.Err, &x), "the run fails with an ExitError")
+	assertion.Equal(t, command.ExitStatus(result.Err), 3, "exit status of the Action")
+	assertion.Lines(t, result.Stdout, []string{"a"})
+
+	result = run.Command(command.Awk(ExitProgram{})).WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "END: awk: exit status 4")
+	assertion.Equal(t, command.ExitStatus(result.Err), 4, "exit status of End")
+}
+
+func TestExitError_Pipe(t *testing.T) {
+	result := run.Command(command.Pipe(command.SimpleProgram{}, ExitProgram{})).WithStdinLines("exit").Run()
+
+	assertion.Equal(t, command.ExitStatus(result.Err), 3, "exit status of a stage")
+}
+
+func TestExitStatus(t *testing.T) {
+	assertion.Equal(t, command.ExitStatus(nil), 0, "success")
+	assertion.Equal(t, command.ExitStatus(errCorrupt), 2, "any other error")
+	assertion.Equal(t, command.ExitStatus(&command.ExitError{}), 0, "exit 0")
+}
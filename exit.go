@@ -0,0 +1,38 @@
+package command
+
+import "errors"
+
+// Exit stops the run like awk's exit statement: no further records are read
+// (from Begin, none at all), End still runs, and the run then fails with an
+// *ExitError carrying code, or succeeds when code is 0. Unlike Abort, the
+// output of the current record, or of End, is kept, so an Action can print a
+// record and exit. Calling Exit again, from End for instance, changes the
+// code.
+func (c *Context) Exit(code int) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	c.exit = &ExitError{Code: code}
+}
+
+// exited returns the ExitError of the run if the Program called Exit
+func (c *Context) exited() *ExitError {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	return c.exit
+}
+
+// errExit stops reading the inputs once the Program has called Exit
+var errExit = errors.New("exit")
+
+// exitError returns the error a run ends with after End: the ExitError of a
+// non-zero exit status, or nil
+func (e *engine) exitError() error {
+	if x := e.ctx.exited(); x != nil && x.Code != 0 {
+		return e.errorf("%w", x)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package command_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// StopProgram prints the records before NR at, then exits with code; at 0
+// it exits in Begin. With print, the record at NR at is printed too, like
+// awk 'NR == 2 { print; exit 3 } { print }'. End reports where the run
+// stopped.
+type StopProgram struct {
+	command.SimpleProgram
+	at    int64
+	code  int
+	print bool
+}
+
+func (p StopProgram) Begin(ctx *command.Context) error {
+	if p.at == 0 {
+		ctx.Exit(p.code)
+	}
+	return nil
+}
+
+func (p StopProgram) Action(ctx *command.Context) (string, bool) {
+	if ctx.NR == p.at {
+		ctx.Exit(p.code)
+		return ctx.Field(0), p.print
+	}
+	return ctx.Field(0), true
+}
+
+func (p StopProgram) End(ctx *command.Context) (string, error) {
+	return fmt.Sprintf("END at NR=%d", ctx.NR), nil
+}
+
+func TestContext_Exit(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(StopProgram{at: 2}, command.StatsRecipient(&stats))).
+		WithStdinLines(numbers(10)...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1", "END at NR=2"})
+	assertion.Equal(t, stats.Records, int64(2), "records read")
+	assertion.Equal(t, stats.Emitted, int64(2), "records emitted, End's included")
+	assertion.True(t, stats.StoppedEarly, "stopped early")
+}
+
+func TestContext_Exit_Code(t *testing.T) {
+	result := run.Command(command.Awk(StopProgram{at: 2, code: 3, print: true})).
+		WithStdinLines(numbers(10)...).Run()
+
+	var x *command.ExitError
+	assertion.True(t, errors.As(result.Err, &x), "the run fails with an ExitError")
+	assertion.Equal(t, command.ExitStatus(result.Err), 3, "exit status")
+	assertion.Lines(t, result.Stdout, []string{"1", "2", "END at NR=2"})
+}
+
+func TestContext_Exit_Begin(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(StopProgram{at: 0, code: 1}, command.StatsRecipient(&stats))).
+		WithStdinLines(numbers(3)...).Run()
+
+	assertion.Equal(t, command.ExitStatus(result.Err), 1, "exit status")
+	assertion.Lines(t, result.Stdout, []string{"END at NR=0"})
+	assertion.True(t, stats.StoppedEarly, "stopped before reading")
+}
+
+func TestContext_Exit_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "1", "2")
+	second := writeFile(t, "second.txt", "3")
+	result := run.Command(command.Awk(StopProgram{at: 1}, first, second)).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"END at NR=1"})
+}
+
+func TestContext_Exit_Pipe(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Pipe(
+		command.Stage(StopProgram{at: -1}, command.StatsRecipient(&stats)),
+		StopProgram{at: 3, code: 2},
+	)).WithStdinLines(numbers(10)...).Run()
+
+	assertion.Equal(t, command.ExitStatus(result.Err), 2, "exit status of the second stage")
+	assertion.Lines(t, result.Stdout, []string{"1", "2", "END at NR=3"})
+	assertion.Equal(t, stats.Records, int64(3), "the first stage stops reading")
+	assertion.True(t, stats.StoppedEarly, "the first stage stopped early")
+}
+
+func TestContext_Exit_Rules(t *testing.T) {
+	result := run.Command(command.Awk(command.Rules(StopProgram{at: 2}, LineNumberProgram{}))).
+		WithStdinLines(numbers(5)...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1", "1: 1", "END at NR=2"})
+}
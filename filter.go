@@ -0,0 +1,52 @@
+package command
+
+import (
+	"regexp"
+	"slices"
+)
+
+// FilterOption changes how Filter, FilterField and FilterFunc select records
+type FilterOption int
+
+const (
+	// Invert keeps the records the predicate rejects, like grep -v
+	Invert FilterOption = iota + 1
+
+	// IgnoreCase matches regular expressions regardless of case, like grep -i
+	IgnoreCase
+)
+
+// filter is the Program of Filter, FilterField and FilterFunc
+type filter struct {
+	SimpleProgram
+	match  func(ctx *Context) bool
+	invert bool
+}
+
+func (f filter) Condition(ctx *Context) bool {
+	return f.match(ctx) != f.invert
+}
+
+// Filter returns a Program emitting the records whose $0 matches the regular
+// expression re unchanged, like grep. It fails if re does not compile.
+func Filter(re string, options ...FilterOption) (Program, error) {
+	return FilterField(0, re, options...)
+}
+
+// FilterField is Filter matching field i (negative indexes count from the end)
+func FilterField(i int, re string, options ...FilterOption) (Program, error) {
+	if slices.Contains(options, IgnoreCase) {
+		re = "(?i)" + re
+	}
+	compiled, err := regexp.Compile(re)
+	if err != nil {
+		return nil, err
+	}
+	return FilterFunc(func(ctx *Context) bool { return compiled.MatchString(ctx.Field(i)) }, options...), nil
+}
+
+// FilterFunc returns a Program emitting the records for which match holds
+// unchanged
+func FilterFunc(match func(ctx *Context) bool, options ...FilterOption) Program {
+	return filter{match: match, invert: slices.Contains(options, Invert)}
+}
@@ -0,0 +1,84 @@
+package command_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+var filterInput = []string{
+	"include:line1",
+	"skip this",
+	"INCLUDE:line2",
+	"skip this too",
+}
+
+func TestFilter(t *testing.T) {
+	prog, err := command.Filter("^include:")
+	assertion.NoError(t, err)
+
+	result := run.Command(command.Awk(prog)).WithStdinLines(filterInput...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"include:line1"})
+}
+
+func TestFilter_Options(t *testing.T) {
+	tests := []struct {
+		name    string
+		options []command.FilterOption
+		want    []string
+	}{
+		{"IgnoreCase", []command.FilterOption{command.IgnoreCase}, []string{"include:line1", "INCLUDE:line2"}},
+		{"Invert", []command.FilterOption{command.Invert}, []string{"skip this", "INCLUDE:line2", "skip this too"}},
+		{"Both", []command.FilterOption{command.Invert, command.IgnoreCase}, []string{"skip this", "skip this too"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := command.Filter("^include:", tt.options...)
+			assertion.NoError(t, err)
+
+			result := run.Command(command.Awk(prog)).WithStdinLines(filterInput...).Run()
+			assertion.NoError(t, result.Err)
+			assertion.Lines(t, result.Stdout, tt.want)
+		})
+	}
+}
+
+func TestFilter_Invalid(t *testing.T) {
+	_, err := command.Filter("([")
+	assertion.ErrorContains(t, err, "missing closing ]")
+}
+
+func TestFilterField(t *testing.T) {
+	prog, err := command.FilterField(-1, `^\d+$`)
+	assertion.NoError(t, err)
+
+	var stats command.Stats
+	result := run.Command(command.Awk(prog, command.StatsRecipient(&stats))).
+		WithStdinLines("a 1", "b two", "c 3 x", "d 4").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 1", "d 4"})
+	assertion.Equal(t, stats.Skipped, int64(2), "rejected records are skipped")
+}
+
+func TestFilterFunc(t *testing.T) {
+	prog := command.FilterFunc(func(ctx *command.Context) bool {
+		return strings.HasPrefix(ctx.Field(0), "include:")
+	})
+
+	result := run.Command(command.Awk(prog)).WithStdinLines(filterInput...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"include:line1"})
+}
+
+func TestFilter_Pipe(t *testing.T) {
+	prog, err := command.Filter("line", command.IgnoreCase)
+	assertion.NoError(t, err)
+
+	result := run.Command(command.Pipe(prog, LineNumberProgram{})).WithStdinLines(filterInput...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: include:line1", "2: INCLUDE:line2"})
+}
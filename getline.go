@@ -0,0 +1,31 @@
+package command
+
+// Getline replaces the current record with the next one of the input, like
+// awk's plain getline: $0, the fields, NF, NR, FNR and RT are those of the
+// new record, which the run then does not process again. It returns the new
+// $0, or false at the end of the current input, in which case the record is
+// left as it was. Getline only reads from the input being processed: in End
+// and Begin, and in the second stage of a Pipe, which is handed its records
+// one at a time, it returns false. An error reading the input fails the run
+// once the current record is processed.
+func (c *Context) Getline() (line string, ok bool) {
+	if c.getline == nil {
+		return "", false
+	}
+	return c.getline()
+}
+
+// getline makes the record read by Getline the current one, counted like
+// the records the run reads itself
+func (e *engine) getline(fields []string, line, rt string) string {
+	if e.ctx.lookback != nil {
+		e.ctx.lookback.push(e.seen)
+	}
+	e.ctx.RT = rt
+	e.ctx.NR++
+	e.ctx.FNR++
+	e.stats.Records++
+	e.observeRecord()
+	e.load(fields, line)
+	return e.ctx.Field(0)
+}
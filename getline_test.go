@@ -0,0 +1,102 @@
+package command_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// PairProgram merges each header line with the value line after it, like
+// awk '{ header = $0; if ((getline) > 0) print header "=" $0, NR; else print header "=" }'
+type PairProgram struct {
+	command.SimpleProgram
+}
+
+func (p PairProgram) Action(ctx *command.Context) (string, bool) {
+	header := ctx.Field(0)
+	value, ok := ctx.Getline()
+	if !ok {
+		return header + "=", true
+	}
+	return fmt.Sprintf("%s=%s NR=%d FNR=%d NF=%d", header, value, ctx.NR, ctx.FNR, ctx.NF), true
+}
+
+func (p PairProgram) End(ctx *command.Context) (string, error) {
+	_, ok := ctx.Getline()
+	return fmt.Sprintf("END NR=%d getline=%t", ctx.NR, ok), nil
+}
+
+func TestContext_Getline(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(PairProgram{}, command.StatsRecipient(&stats))).
+		WithStdinLines("name", "alice smith", "age", "30", "orphan").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"name=alice smith NR=2 FNR=2 NF=2",
+		"age=30 NR=4 FNR=4 NF=1",
+		"orphan=",
+		"END NR=5 getline=false",
+	})
+	assertion.Equal(t, stats.Records, int64(5), "records read")
+}
+
+func TestContext_Getline_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "a", "1", "b")
+	second := writeFile(t, "second.txt", "c", "2")
+	result := run.Command(command.Awk(PairProgram{}, first, second)).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"a=1 NR=2 FNR=2 NF=1",
+		"b=",
+		"c=2 NR=5 FNR=2 NF=1",
+		"END NR=5 getline=false",
+	})
+}
+
+func TestContext_Getline_TailRecords(t *testing.T) {
+	result := run.Command(command.Awk(PairProgram{}, command.TailRecords(4))).
+		WithStdinLines("x", "y", "a", "1", "b", "2").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"a=1 NR=4 FNR=4 NF=1",
+		"b=2 NR=6 FNR=6 NF=1",
+		"END NR=6 getline=false",
+	})
+}
+
+// PrevProgram prints the previous record
+type PrevProgram struct {
+	command.SimpleProgram
+}
+
+func (p PrevProgram) Action(ctx *command.Context) (string, bool) {
+	return fmt.Sprintf("prev of %s: %s", ctx.Field(0), ctx.Prev(1).Text()), true
+}
+
+func TestContext_Getline_Lookback(t *testing.T) {
+	result := run.Command(command.Awk(command.Rules(PairProgram{}, PrevProgram{}), command.Lookback(2))).
+		WithStdinLines("a", "1", "b", "2").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"a=1 NR=2 FNR=2 NF=1",
+		"prev of 1: a",
+		"b=2 NR=4 FNR=4 NF=1",
+		"prev of 2: b",
+		"END NR=4 getline=false",
+	})
+}
+
+func TestContext_Getline_PipeStage(t *testing.T) {
+	result := run.Command(command.Pipe(command.SimpleProgram{}, PairProgram{})).
+		WithStdinLines("a", "1").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a=", "1=", "END NR=2 getline=false"})
+}
@@ -0,0 +1,54 @@
+package command
+
+import (
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	gloo "github.com/gloo-foo/framework"
+)
+
+// Glob expands file operands holding *, ? or [ with filepath.Glob, as a shell
+// would, into the files they match in sorted order. A pattern matching nothing
+// is reported like a file that cannot be opened, unless NullGlob is set.
+type Glob bool
+
+// NullGlob lets a Glob pattern matching nothing expand to no files
+type NullGlob bool
+
+func (g Glob) Configure(flags *flags)     { flags.Glob = g }
+func (n NullGlob) Configure(flags *flags) { flags.NullGlob = n }
+
+// errNoMatch is the error of a Glob pattern matching no files
+var errNoMatch = errors.New("no files match the pattern")
+
+// expand returns the file operands with their Glob patterns expanded
+func (e *engine) expand(files []gloo.File) ([]gloo.File, error) {
+	if !e.glob {
+		return files, nil
+	}
+	expanded := make([]gloo.File, 0, len(files))
+	for _, file := range files {
+		pattern := string(file)
+		if pattern == "-" || !strings.ContainsAny(pattern, "*?[") {
+			expanded = append(expanded, file)
+			continue
+		}
+		matches, err := filepath.Glob(pattern)
+		if err == nil && len(matches) == 0 && !e.nullGlob {
+			err = errNoMatch
+		}
+		if err != nil {
+			if err := e.fileError(&FileError{Name: pattern, Err: err}); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		slices.Sort(matches)
+		for _, m := range matches {
+			expanded = append(expanded, gloo.File(m))
+		}
+	}
+	return expanded, nil
+}
@@ -0,0 +1,52 @@
+package command_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestAwk_Glob(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.log"), filepath.Join(dir, "b.log")
+	for name, data := range map[string]string{b: "two\n", a: "one\n", filepath.Join(dir, "c.txt"): "skipped\n"} {
+		assertion.NoError(t, os.WriteFile(name, []byte(data), 0o644))
+	}
+	var stats command.Stats
+	result := run.Command(command.Awk(LineNumberProgram{}, filepath.Join(dir, "*.log"), command.Glob(true),
+		command.StatsRecipient(&stats))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: one", "2: two"})
+	assertion.Equal(t, fmt.Sprint(stats.Files), fmt.Sprintf("[{%s 1} {%s 1}]", a, b), "expanded names")
+}
+
+func TestAwk_Glob_NoMatch(t *testing.T) {
+	pattern := filepath.Join(t.TempDir(), "*.log")
+	result := run.Command(command.Awk(&CountingProgram{}, pattern, command.Glob(true))).Run()
+
+	var fe *command.FileError
+	assertion.True(t, errors.As(result.Err, &fe), "the run fails with a FileError")
+	assertion.Equal(t, fe.Name, pattern, "the pattern is named")
+	assertion.Lines(t, result.Stderr, []string{"awk: can't open file " + pattern + ": no files match the pattern"})
+	assertion.Lines(t, result.Stdout, []string{"Total lines: 0"})
+
+	result = run.Command(command.Awk(&CountingProgram{}, pattern, command.Glob(true), command.NullGlob(true))).
+		WithStdinLines("not read").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"Total lines: 0"})
+}
+
+func TestAwk_Glob_Off(t *testing.T) {
+	pattern := filepath.Join(filepath.Dir(writeFile(t, "a.log", "one")), "*.log")
+	result := run.Command(command.Awk(command.SimpleProgram{}, pattern)).Run()
+
+	assertion.ErrorContains(t, result.Err, "can't open file "+pattern)
+}
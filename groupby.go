@@ -0,0 +1,134 @@
+package command
+
+import (
+	"fmt"
+	"math"
+)
+
+// Accumulator aggregates the values of one group of GroupBy
+type Accumulator interface {
+	Add(value float64)
+	Result() float64
+}
+
+// TextAccumulator is an Accumulator of values that need not be numbers:
+// GroupBy calls AddText with the field's text instead of Add
+type TextAccumulator interface {
+	Accumulator
+	AddText(value string)
+}
+
+// Aggregator creates the Accumulator of a new group. Sum, Count, Min, Max,
+// Avg and CountDistinct are Aggregators; any function returning a custom
+// Accumulator is one too.
+type Aggregator func() Accumulator
+
+// Sum adds up the values of a group
+func Sum() Accumulator { return new(sum) }
+
+// Count counts the records of a group, whatever their values
+func Count() Accumulator { return new(count) }
+
+// Min keeps the smallest value of a group
+func Min() Accumulator {
+	return &extreme{value: math.Inf(1), better: func(a, b float64) bool { return a < b }}
+}
+
+// Max keeps the largest value of a group
+func Max() Accumulator {
+	return &extreme{value: math.Inf(-1), better: func(a, b float64) bool { return a > b }}
+}
+
+// Avg averages the values of a group
+func Avg() Accumulator { return new(avg) }
+
+// CountDistinct counts the different values of a group, compared as text
+func CountDistinct() Accumulator { return &distinct{seen: make(map[string]struct{})} }
+
+type sum float64
+
+func (s *sum) Add(value float64) { *s += sum(value) }
+func (s *sum) Result() float64   { return float64(*s) }
+
+type count float64
+
+func (c *count) Add(float64)     { *c++ }
+func (c *count) AddText(string)  { *c++ }
+func (c *count) Result() float64 { return float64(*c) }
+
+type extreme struct {
+	value  float64
+	better func(a, b float64) bool
+}
+
+func (e *extreme) Add(value float64) {
+	if e.better(value, e.value) {
+		e.value = value
+	}
+}
+func (e *extreme) Result() float64 { return e.value }
+
+type avg struct {
+	sum float64
+	n   int
+}
+
+func (a *avg) Add(value float64) { a.sum, a.n = a.sum+value, a.n+1 }
+func (a *avg) Result() float64   { return a.sum / float64(a.n) }
+
+type distinct struct {
+	seen map[string]struct{}
+}
+
+func (d *distinct) Add(value float64)    { d.AddText(fmt.Sprint(value)) }
+func (d *distinct) AddText(value string) { d.seen[value] = struct{}{} }
+func (d *distinct) Result() float64      { return float64(len(d.seen)) }
+
+// groupBy is the Program of GroupBy
+type groupBy struct {
+	SimpleProgram
+	key, value int
+	aggregator Aggregator
+}
+
+// GroupBy returns a Program aggregating field valueField of the records by
+// the value of field keyField, like awk '{ sum[$1] += $2 } END { for (k in
+// sum) print k, sum[k] }'. End emits a "key OFS result" record per group,
+// sorted by key as SortedByKey does. Values that are not numbers are skipped
+// and counted in Stats.Warnings, unless the Accumulator is a TextAccumulator.
+func GroupBy(keyField, valueField int, agg Aggregator) Program {
+	return &groupBy{key: keyField, value: valueField, aggregator: agg}
+}
+
+// groups returns the groups of the current run
+func (g *groupBy) groups(ctx *Context) map[string]Accumulator {
+	return ctx.state(g, func() any { return make(map[string]Accumulator) }).(map[string]Accumulator)
+}
+
+func (g *groupBy) Action(ctx *Context) (string, bool) {
+	key, text := ctx.Field(g.key), ctx.Field(g.value)
+	groups := g.groups(ctx)
+	acc, ok := groups[key]
+	if !ok {
+		acc = g.aggregator()
+	}
+	if t, isText := acc.(TextAccumulator); isText {
+		t.AddText(text)
+	} else if value, isNumber := number(text); isNumber {
+		acc.Add(value)
+	} else {
+		ctx.warn()
+		return "", false
+	}
+	groups[key] = acc
+	return "", false
+}
+
+func (g *groupBy) End(ctx *Context) (string, error) {
+	for _, e := range SortedByKey(g.groups(ctx)) {
+		if err := ctx.EmitFields(e.Key, e.Value.Result()); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,95 @@
+package command_test
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+var sales = []string{
+	"books 12.5 alice",
+	"games 30 bob",
+	"books 7.5 bob",
+	"music n/a alice",
+	"games 10 bob",
+	"music 4 carol",
+}
+
+func TestGroupBy(t *testing.T) {
+	tests := []struct {
+		name string
+		agg  command.Aggregator
+		want []string
+	}{
+		{"Sum", command.Sum, []string{"books 20", "games 40", "music 4"}},
+		{"Count", command.Count, []string{"books 2", "games 2", "music 2"}},
+		{"Min", command.Min, []string{"books 7.5", "games 10", "music 4"}},
+		{"Max", command.Max, []string{"books 12.5", "games 30", "music 4"}},
+		{"Avg", command.Avg, []string{"books 10", "games 20", "music 4"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := run.Command(command.Awk(command.GroupBy(1, 2, tt.agg))).WithStdinLines(sales...).Run()
+			assertion.NoError(t, result.Err)
+			assertion.Lines(t, result.Stdout, tt.want)
+		})
+	}
+}
+
+func TestGroupBy_Warnings(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(command.GroupBy(1, 2, command.Sum), command.StatsRecipient(&stats))).
+		WithStdinLines(sales...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, stats.Warnings, int64(1), "the non-numeric value is counted")
+	assertion.Equal(t, stats.Emitted, int64(3), "one record per group")
+}
+
+func TestGroupBy_NotNumbers(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(command.GroupBy(1, 2, command.Max), command.StatsRecipient(&stats))).
+		WithStdinLines("a 1", "a nan", "a +Inf", "b 0x1p4", "b 2").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 1", "b 2"})
+	assertion.Equal(t, stats.Warnings, int64(3), "nan, inf and hexadecimal values are skipped")
+}
+
+func TestGroupBy_CountDistinct(t *testing.T) {
+	result := run.Command(command.Awk(command.GroupBy(3, 1, command.CountDistinct))).WithStdinLines(sales...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"alice 2", "bob 2", "carol 1"})
+}
+
+func TestGroupBy_NumericKeys(t *testing.T) {
+	result := run.Command(command.Awk(command.GroupBy(1, 2, command.Sum), command.OutputFieldSeparator(","))).
+		WithStdinLines("10 1", "9 2", "10 3").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"9,2", "10,4"})
+}
+
+// median is a custom Accumulator
+type median struct{ values []float64 }
+
+func (m *median) Add(value float64) { m.values = append(m.values, value) }
+
+func (m *median) Result() float64 {
+	sort.Float64s(m.values)
+	return m.values[len(m.values)/2]
+}
+
+func TestGroupBy_Custom(t *testing.T) {
+	prog := command.GroupBy(1, 2, func() command.Accumulator { return &median{} })
+	result := run.Command(command.Awk(prog)).WithStdinLines("a 1", "a 9", "a 3", "b 5").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 3", "b 5"})
+
+	// The Program starts over on every run
+	result = run.Command(command.Awk(prog)).WithStdinLines("c 2").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"c 2"})
+}
@@ -0,0 +1,164 @@
+package command
+
+import (
+	"io"
+	"time"
+)
+
+// Hooks are callbacks run around a Program's methods by Wrap.
+// Any of them may be nil.
+type Hooks struct {
+	// OnBegin is called before the Program's Begin
+	OnBegin func(ctx *Context)
+
+	// BeforeRecord is called for every record, before the Program's Condition
+	BeforeRecord func(ctx *Context)
+
+	// OnSkip is called for every record rejected by the Program's Condition
+	OnSkip func(ctx *Context)
+
+	// AfterRecord is called for every record once the Program is done with it.
+	// emitted is false when Condition rejected the record or Action did not emit.
+	// output is empty for an ActionWriter, which wrote its records itself.
+	AfterRecord func(ctx *Context, output string, emitted bool)
+
+	// OnEnd is called after the Program's End with its results
+	OnEnd func(ctx *Context, output string, err error)
+}
+
+type wrapped struct {
+	Program
+	hooks Hooks
+}
+
+// wrappedWriter is a wrapped ActionWriter
+type wrappedWriter struct {
+	wrapped
+	writer ActionWriter
+}
+
+// wrappedMultiPass is a wrapped MultiPass Program
+type wrappedMultiPass struct {
+	wrapped
+	MultiPass
+}
+
+// wrappedMultiPassWriter is a wrapped MultiPass ActionWriter
+type wrappedMultiPassWriter struct {
+	wrappedWriter
+	MultiPass
+}
+
+// Wrap returns a Program that runs hooks around p. The same p instance is
+// called, so pointer-receiver Programs keep their state. Wrapping a wrapped
+// Program nests the hooks: the outer BeforeRecord runs first, its AfterRecord last.
+// The Program returned is a MultiPass or an ActionWriter when p is one; the
+// record hooks then run on every pass.
+func Wrap(p Program, hooks Hooks) Program {
+	w := wrapped{Program: p, hooks: hooks}
+	mp, multi := p.(MultiPass)
+	aw, writer := p.(ActionWriter)
+	switch {
+	case multi && writer:
+		return wrappedMultiPassWriter{wrappedWriter: wrappedWriter{wrapped: w, writer: aw}, MultiPass: mp}
+	case multi:
+		return wrappedMultiPass{wrapped: w, MultiPass: mp}
+	case writer:
+		return wrappedWriter{wrapped: w, writer: aw}
+	}
+	return w
+}
+
+func (w wrapped) Begin(ctx *Context) error {
+	if w.hooks.OnBegin != nil {
+		w.hooks.OnBegin(ctx)
+	}
+	return w.Program.Begin(ctx)
+}
+
+func (w wrapped) Condition(ctx *Context) bool {
+	if w.hooks.BeforeRecord != nil {
+		w.hooks.BeforeRecord(ctx)
+	}
+	if w.Program.Condition(ctx) {
+		return true
+	}
+	if w.hooks.OnSkip != nil {
+		w.hooks.OnSkip(ctx)
+	}
+	if w.hooks.AfterRecord != nil {
+		w.hooks.AfterRecord(ctx, "", false)
+	}
+	return false
+}
+
+func (w wrapped) Action(ctx *Context) (string, bool) {
+	output, emit := w.Program.Action(ctx)
+	if w.hooks.AfterRecord != nil {
+		w.hooks.AfterRecord(ctx, output, emit)
+	}
+	return output, emit
+}
+
+func (w wrappedWriter) ActionWriter(ctx *Context, out io.Writer) (bool, error) {
+	emitted, err := w.writer.ActionWriter(ctx, out)
+	if w.hooks.AfterRecord != nil {
+		w.hooks.AfterRecord(ctx, "", emitted)
+	}
+	return emitted, err
+}
+
+func (w wrapped) End(ctx *Context) (string, error) {
+	output, err := w.Program.End(ctx)
+	if w.hooks.OnEnd != nil {
+		w.hooks.OnEnd(ctx, output, err)
+	}
+	return output, err
+}
+
+// Reset forwards to the wrapped Program when it is a Resetter
+func (w wrapped) Reset() {
+	if r, ok := w.Program.(Resetter); ok {
+		r.Reset()
+	}
+}
+
+// Metrics counts records and times a run; attach it with Wrap(p, m.Hooks())
+type Metrics struct {
+	// Records is the number of records seen
+	Records int64
+
+	// Skipped is the number of records rejected by Condition
+	Skipped int64
+
+	// Emitted is the number of records for which Action emitted output
+	Emitted int64
+
+	// Elapsed is the time from Begin to the end of End
+	Elapsed time.Duration
+}
+
+// Hooks returns hooks that fill in m
+func (m *Metrics) Hooks() Hooks {
+	var start time.Time
+	return Hooks{
+		OnBegin: func(ctx *Context) {
+			*m = Metrics{}
+			start = time.Now()
+		},
+		BeforeRecord: func(ctx *Context) {
+			m.Records++
+		},
+		OnSkip: func(ctx *Context) {
+			m.Skipped++
+		},
+		AfterRecord: func(ctx *Context, output string, emitted bool) {
+			if emitted {
+				m.Emitted++
+			}
+		},
+		OnEnd: func(ctx *Context, output string, err error) {
+			m.Elapsed = time.Since(start)
+		},
+	}
+}
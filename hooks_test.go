@@ -0,0 +1,95 @@
+package command_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// recordingHooks appends a tagged entry to log for every hook invocation
+func recordingHooks(tag string, log *[]string) command.Hooks {
+	return command.Hooks{
+		OnBegin: func(ctx *command.Context) {
+			*log = append(*log, tag+":begin")
+		},
+		BeforeRecord: func(ctx *command.Context) {
+			*log = append(*log, fmt.Sprintf("%s:before:%d", tag, ctx.NR))
+		},
+		OnSkip: func(ctx *command.Context) {
+			*log = append(*log, fmt.Sprintf("%s:skip:%d", tag, ctx.NR))
+		},
+		AfterRecord: func(ctx *command.Context, output string, emitted bool) {
+			*log = append(*log, fmt.Sprintf("%s:after:%d:%s:%t", tag, ctx.NR, output, emitted))
+		},
+		OnEnd: func(ctx *command.Context, output string, err error) {
+			*log = append(*log, fmt.Sprintf("%s:end:%s", tag, output))
+		},
+	}
+}
+
+func TestWrap_HookOrder(t *testing.T) {
+	var log []string
+	prog := command.Wrap(
+		command.Wrap(ConditionalProgram{}, recordingHooks("inner", &log)),
+		recordingHooks("outer", &log),
+	)
+
+	result := run.Command(command.Awk(prog)).
+		WithStdinLines("include:a", "skip").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"include:a"})
+	assertion.Equal(t, strings.Join(log, " "), strings.Join([]string{
+		"outer:begin", "inner:begin",
+		"outer:before:1", "inner:before:1", "inner:after:1:include:a:true", "outer:after:1:include:a:true",
+		"outer:before:2", "inner:before:2", "inner:skip:2", "inner:after:2::false", "outer:skip:2", "outer:after:2::false",
+		"inner:end:", "outer:end:",
+	}, " "), "hook order")
+}
+
+func TestWrap_PreservesPointerPrograms(t *testing.T) {
+	prog := &CountingProgram{}
+	result := run.Command(command.Awk(command.Wrap(prog, command.Hooks{}))).
+		WithStdinLines("a", "b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"Total lines: 2"})
+	assertion.Equal(t, prog.count, 2, "state kept on the wrapped instance")
+}
+
+func TestMetrics(t *testing.T) {
+	var metrics command.Metrics
+	result := run.Command(command.Awk(command.Wrap(ConditionalProgram{}, metrics.Hooks()))).
+		WithStdinLines("include:1", "skip", "include:2", "skip").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, metrics.Records, int64(4), "records")
+	assertion.Equal(t, metrics.Skipped, int64(2), "skipped")
+	assertion.Equal(t, metrics.Emitted, int64(2), "emitted")
+	assertion.True(t, metrics.Elapsed > 0, "elapsed time recorded")
+}
+
+func TestWrap_MultiPass(t *testing.T) {
+	var total float64
+	var passes []string
+	var metrics command.Metrics
+	prog := command.Wrap(PercentProgram{total: &total, passes: &passes}, metrics.Hooks())
+
+	output := execute(t, prog, "a 1\nb 3\n")
+	assertion.Equal(t, output, "1 a 25.0%\n2 b 75.0%\ntotal 4\n", "both passes run")
+	assertion.Equal(t, metrics.Records, int64(4), "record hooks run on every pass")
+}
+
+func TestWrap_ActionWriter(t *testing.T) {
+	var log []string
+	prog := command.Wrap(ExplodeProgram{}, recordingHooks("w", &log))
+
+	output := execute(t, prog, "2 a\n0 b\n")
+	assertion.Equal(t, output, "a 1\na 2\ndone\n", "ActionWriter used")
+	assertion.Equal(t, strings.Join(log, " "),
+		"w:begin w:before:1 w:after:1::true w:before:2 w:after:2::false w:end:done", "hooks")
+}
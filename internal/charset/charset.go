@@ -0,0 +1,152 @@
+// Package charset converts between UTF-8 and the single-byte character sets
+// legacy exports are commonly written in.
+package charset
+
+import (
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// Charset is a single-byte character set
+type Charset struct {
+	decode [256]rune
+	encode map[rune]byte
+}
+
+// windows1252 are the characters Windows-1252 puts where ISO-8859-1 has C1
+// controls; undefined bytes keep their ISO-8859-1 meaning
+var windows1252 = map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}
+
+func newCharset(overrides map[byte]rune) *Charset {
+	c := &Charset{encode: make(map[rune]byte, 256)}
+	for i := range 256 {
+		r := rune(i)
+		if o, ok := overrides[byte(i)]; ok {
+			r = o
+		}
+		c.decode[i] = r
+		c.encode[r] = byte(i)
+	}
+	return c
+}
+
+var (
+	latin1 = newCharset(nil)
+	cp1252 = newCharset(windows1252)
+)
+
+// Lookup returns the character set with the given name. UTF-8 is valid and
+// returns nil, as it needs no conversion.
+func Lookup(name string) (c *Charset, ok bool) {
+	switch strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(name)) {
+	case "utf8":
+		return nil, true
+	case "iso88591", "latin1", "l1":
+		return latin1, true
+	case "windows1252", "cp1252":
+		return cp1252, true
+	default:
+		return nil, false
+	}
+}
+
+// NewReader returns a reader converting the text of r to UTF-8
+func (c *Charset) NewReader(r io.Reader) io.Reader {
+	return &reader{c: c, r: r}
+}
+
+type reader struct {
+	c   *Charset
+	r   io.Reader
+	in  []byte
+	out []byte
+
+	// err is the error of r, held until out is drained
+	err error
+}
+
+func (d *reader) Read(p []byte) (int, error) {
+	if len(d.out) > 0 {
+		n := copy(p, d.out)
+		d.out = d.out[n:]
+		if len(d.out) > 0 {
+			return n, nil
+		}
+		err := d.err
+		d.err = nil
+		return n, err
+	}
+	// Every byte decodes to at most 3 bytes of UTF-8
+	if cap(d.in) < len(p)/3+1 {
+		d.in = make([]byte, len(p)/3+1)
+	}
+	n, err := d.r.Read(d.in[:len(p)/3+1])
+	out := d.out[:0]
+	for _, b := range d.in[:n] {
+		out = utf8.AppendRune(out, d.c.decode[b])
+	}
+	m := copy(p, out)
+	d.out = out[m:]
+	if len(d.out) > 0 {
+		d.err = err
+		return m, nil
+	}
+	return m, err
+}
+
+// NewWriter returns a writer converting UTF-8 text to the character set
+// before writing it to w. Characters the set lacks are written as '?'.
+// Close writes a '?' for an incomplete UTF-8 sequence ending the text; it
+// does not close w.
+func (c *Charset) NewWriter(w io.Writer) io.WriteCloser {
+	return &writer{c: c, w: w}
+}
+
+type writer struct {
+	c       *Charset
+	w       io.Writer
+	partial []byte // an incomplete UTF-8 sequence ending the previous write
+	out     []byte
+}
+
+func (e *writer) Write(p []byte) (int, error) {
+	data := p
+	if len(e.partial) > 0 {
+		data = append(e.partial, p...)
+		e.partial = nil
+	}
+	out := e.out[:0]
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size <= 1 && !utf8.FullRune(data) {
+			e.partial = append([]byte(nil), data...)
+			break
+		}
+		b, ok := e.c.encode[r]
+		if !ok || (r == utf8.RuneError && size == 1) {
+			b = '?'
+		}
+		out = append(out, b)
+		data = data[size:]
+	}
+	e.out = out
+	if _, err := e.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (e *writer) Close() error {
+	if len(e.partial) == 0 {
+		return nil
+	}
+	e.partial = nil
+	_, err := e.w.Write([]byte{'?'})
+	return err
+}
@@ -0,0 +1,93 @@
+package charset_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/yupsh/awk/internal/charset"
+)
+
+func TestLookup(t *testing.T) {
+	for _, name := range []string{"UTF-8", "utf8", "ISO-8859-1", "latin1", "Windows-1252", "cp1252"} {
+		_, ok := charset.Lookup(name)
+		assertion.True(t, ok, name)
+	}
+	_, ok := charset.Lookup("klingon")
+	assertion.False(t, ok, "unknown names are rejected")
+}
+
+func TestReader(t *testing.T) {
+	latin1, _ := charset.Lookup("latin1")
+	out, err := io.ReadAll(latin1.NewReader(bytes.NewReader([]byte("caf\xe9\n\x80"))))
+	assertion.NoError(t, err)
+	assertion.Equal(t, string(out), "café\n\u0080", "ISO-8859-1")
+
+	cp1252, _ := charset.Lookup("windows-1252")
+	out, err = io.ReadAll(iotest.OneByteReader(cp1252.NewReader(strings.NewReader("\x93caf\xe9\x94 \x80"))))
+	assertion.NoError(t, err)
+	assertion.Equal(t, string(out), "“café” €", "Windows-1252, read a byte at a time")
+}
+
+// eofReader returns its data and io.EOF from the same Read, as gzip readers
+// do at the end of the stream
+type eofReader struct{ data []byte }
+
+func (r *eofReader) Read(p []byte) (int, error) {
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	if len(r.data) == 0 {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+func TestReader_DataWithEOF(t *testing.T) {
+	// 1366 euro signs decode to 4098 bytes, more than the buffer holds, in the
+	// read that also returns EOF
+	cp1252, _ := charset.Lookup("cp1252")
+	r := cp1252.NewReader(&eofReader{data: bytes.Repeat([]byte{0x80}, 1366)})
+
+	var out []byte
+	buf := make([]byte, 4096)
+	for {
+		n, err := r.Read(buf)
+		out = append(out, buf[:n]...)
+		if err == io.EOF {
+			break
+		}
+		assertion.NoError(t, err)
+	}
+	assertion.Equal(t, string(out), strings.Repeat("€", 1366), "every decoded byte is returned before EOF")
+}
+
+func TestWriter(t *testing.T) {
+	cp1252, _ := charset.Lookup("cp1252")
+	var buf bytes.Buffer
+	w := cp1252.NewWriter(&buf)
+
+	text := []byte("“café” € 日")
+	for i := range text {
+		// Split every multi-byte character across writes
+		_, err := w.Write(text[i : i+1])
+		assertion.NoError(t, err)
+	}
+	assertion.Equal(t, buf.String(), "\x93caf\xe9\x94 \x80 ?", "encoded, unknown characters as '?'")
+}
+
+func TestWriter_Close(t *testing.T) {
+	latin1, _ := charset.Lookup("latin1")
+	var buf bytes.Buffer
+	w := latin1.NewWriter(&buf)
+
+	_, err := w.Write([]byte("caf\xc3\xa9 \xe6\x97"))
+	assertion.NoError(t, err)
+	assertion.Equal(t, buf.String(), "caf\xe9 ", "the incomplete sequence is held back")
+	assertion.NoError(t, w.Close())
+	assertion.Equal(t, buf.String(), "caf\xe9 ?", "and written as '?' on Close")
+	assertion.NoError(t, w.Close())
+	assertion.Equal(t, buf.String(), "caf\xe9 ?", "once")
+}
@@ -0,0 +1,141 @@
+// Package text implements awk's string functions with awk's 1-based, clamped
+// semantics, in both character (rune) and byte flavors. It is shared by every
+// awk front end in this module so they cannot drift apart.
+package text
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Length returns the number of characters (runes) in s
+func Length(s string) int {
+	return utf8.RuneCountInString(s)
+}
+
+// LengthBytes returns the number of bytes in s
+func LengthBytes(s string) int {
+	return len(s)
+}
+
+// Substr returns at most length characters of s starting at the 1-based
+// position start, clamped to s like awk's substr(s, start, length)
+func Substr(s string, start, length int) string {
+	from, to, ok := span(Length(s), start, length)
+	if !ok {
+		return ""
+	}
+
+	// Convert character positions to byte offsets
+	i, lo, hi := 0, len(s), len(s)
+	for offset := range s {
+		if i == from {
+			lo = offset
+		}
+		if i == to {
+			hi = offset
+			break
+		}
+		i++
+	}
+	return s[lo:hi]
+}
+
+// SubstrBytes is Substr counting bytes instead of characters
+func SubstrBytes(s string, start, length int) string {
+	from, to, ok := span(len(s), start, length)
+	if !ok {
+		return ""
+	}
+	return s[from:to]
+}
+
+// Index returns the 1-based character position of the first t in s, or 0 if
+// s does not contain t, like awk's index(s, t)
+func Index(s, t string) int {
+	i := strings.Index(s, t)
+	if i < 0 {
+		return 0
+	}
+	return Length(s[:i]) + 1
+}
+
+// IndexBytes is Index counting bytes instead of characters
+func IndexBytes(s, t string) int {
+	return strings.Index(s, t) + 1
+}
+
+// Chars splits s into its characters; each byte that is not valid UTF-8 is a
+// character of its own
+func Chars(s string) []string {
+	return strings.Split(s, "")
+}
+
+// Bytes splits s into its bytes
+func Bytes(s string) []string {
+	b := make([]string, len(s))
+	for i := range len(s) {
+		b[i] = s[i : i+1]
+	}
+	return b
+}
+
+// NumericPrefix returns the longest prefix of s, after leading blanks, that
+// awk reads as a number, like strtod: an optional sign, digits with an
+// optional decimal point, and an optional exponent. whole reports whether
+// nothing but blanks follows it. Hexadecimal, inf and nan are not numbers:
+// "0x1A" reads as 0.
+func NumericPrefix(s string) (prefix string, whole bool) {
+	s = strings.TrimLeft(s, " \t\n")
+	i := 0
+	if i < len(s) && (s[i] == '+' || s[i] == '-') {
+		i++
+	}
+	digits := skipDigits(s, i)
+	mantissa := digits - i
+	i = digits
+	if i < len(s) && s[i] == '.' {
+		digits = skipDigits(s, i+1)
+		mantissa += digits - i - 1
+		i = digits
+	}
+	if mantissa == 0 {
+		return "", false
+	}
+	if i < len(s) && (s[i] == 'e' || s[i] == 'E') {
+		j := i + 1
+		if j < len(s) && (s[j] == '+' || s[j] == '-') {
+			j++
+		}
+		if k := skipDigits(s, j); k > j {
+			i = k
+		}
+	}
+	return s[:i], strings.TrimRight(s[i:], " \t\n") == ""
+}
+
+// skipDigits returns the index of the first non-digit of s from i
+func skipDigits(s string, i int) int {
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// span converts awk's 1-based start and length over n units into a 0-based
+// half-open range, reporting false when the range is empty
+func span(n, start, length int) (from, to int, ok bool) {
+	if start < 1 {
+		// Positions before the string count against the length
+		length += start - 1
+		start = 1
+	}
+	if length <= 0 || start > n {
+		return 0, 0, false
+	}
+	from = start - 1
+	if length > n-from {
+		return from, n, true
+	}
+	return from, from + length, true
+}
@@ -0,0 +1,133 @@
+package text_test
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/yupsh/awk/internal/text"
+)
+
+func TestLength(t *testing.T) {
+	tests := []struct {
+		s           string
+		runes, size int
+	}{
+		{"", 0, 0},
+		{"hello", 5, 5},
+		{"日本語", 3, 9},
+		{"Ελληνικά", 8, 16},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			assertion.Equal(t, text.Length(tt.s), tt.runes, "characters")
+			assertion.Equal(t, text.LengthBytes(tt.s), tt.size, "bytes")
+		})
+	}
+}
+
+func TestSubstr(t *testing.T) {
+	tests := []struct {
+		s             string
+		start, length int
+		want          string
+	}{
+		{"hello", 2, 3, "ell"},
+		{"hello", 1, 5, "hello"},
+		{"hello", 1, 100, "hello"},
+		{"hello", 4, math.MaxInt, "lo"},
+		{"hello", 0, 2, "h"},   // position 0 counts against the length
+		{"hello", -1, 4, "he"}, // so do negative positions
+		{"hello", 6, 1, ""},
+		{"hello", 2, 0, ""},
+		{"hello", 2, -1, ""},
+		{"", 1, 1, ""},
+		{"日本語", 2, 1, "本"},
+		{"日本語", 2, 5, "本語"},
+		{"Ελληνικά", 3, 3, "λην"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d/%d", tt.s, tt.start, tt.length), func(t *testing.T) {
+			got := text.Substr(tt.s, tt.start, tt.length)
+			assertion.Equal(t, got, tt.want, "substring")
+			assertion.True(t, utf8.ValidString(got), "valid UTF-8")
+		})
+	}
+}
+
+func TestSubstrBytes(t *testing.T) {
+	tests := []struct {
+		s             string
+		start, length int
+		want          string
+	}{
+		{"hello", 2, 3, "ell"},
+		{"hello", 0, 2, "h"},
+		{"hello", 5, 10, "o"},
+		{"hello", 6, 1, ""},
+		{"日本語", 4, 3, "本"},
+		{"日本語", 1, 1, "\xe6"}, // byte semantics may split a character
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d/%d", tt.s, tt.start, tt.length), func(t *testing.T) {
+			assertion.Equal(t, text.SubstrBytes(tt.s, tt.start, tt.length), tt.want, "substring")
+		})
+	}
+}
+
+func TestIndex(t *testing.T) {
+	tests := []struct {
+		s, t        string
+		runes, size int
+	}{
+		{"hello", "l", 3, 3},
+		{"hello", "", 1, 1},
+		{"hello", "x", 0, 0},
+		{"日本語", "語", 3, 7},
+		{"\xffé\xff!", "!", 4, 5}, // invalid bytes count as one character each
+	}
+	for _, tt := range tests {
+		t.Run(tt.s+"/"+tt.t, func(t *testing.T) {
+			assertion.Equal(t, text.Index(tt.s, tt.t), tt.runes, "characters")
+			assertion.Equal(t, text.IndexBytes(tt.s, tt.t), tt.size, "bytes")
+		})
+	}
+}
+
+func TestChars(t *testing.T) {
+	assertion.Equal(t, fmt.Sprintf("%q", text.Chars("aé\xff")), `["a" "é" "\xff"]`, "characters")
+	assertion.Equal(t, fmt.Sprintf("%q", text.Bytes("aé\xff")), `["a" "\xc3" "\xa9" "\xff"]`, "bytes")
+	assertion.Equal(t, len(text.Chars("")), 0, "no characters")
+	assertion.Equal(t, len(text.Bytes("")), 0, "no bytes")
+}
+
+func TestNumericPrefix(t *testing.T) {
+	tests := []struct {
+		s, prefix string
+		whole     bool
+	}{
+		{"42", "42", true},
+		{"  -3.5  ", "-3.5", true},
+		{"+.5", "+.5", true},
+		{"7.", "7.", true},
+		{"1e3", "1e3", true},
+		{"1e", "1", false},
+		{"2E-2x", "2E-2", false},
+		{"3.5kg", "3.5", false},
+		{"0x1A", "0", false},
+		{"inf", "", false},
+		{".", "", false},
+		{"-", "", false},
+		{"", "", false},
+		{"abc", "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			prefix, whole := text.NumericPrefix(tt.s)
+			assertion.Equal(t, prefix, tt.prefix, "prefix")
+			assertion.Equal(t, whole, tt.whole, "whole")
+		})
+	}
+}
@@ -0,0 +1,32 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+)
+
+// MaxOutputBytes fails the run once writing a record would take the output
+// past n bytes, terminators included, over all the passes of a MultiPass
+// Program. The records written before stay written. Only the run's output
+// counts: the files of OutputFiles do not.
+type MaxOutputBytes int64
+
+func (n MaxOutputBytes) Configure(flags *flags) { flags.MaxOutputBytes = n }
+
+// ErrOutputLimit is the error of a run whose output reached MaxOutputBytes
+var ErrOutputLimit = errors.New("MaxOutputBytes exceeded")
+
+// outputLimit returns the error of writing n more bytes, if that takes the
+// output past MaxOutputBytes
+func (e *engine) outputLimit(n int) error {
+	if e.maxOutput > 0 && e.written+int64(n) > e.maxOutput {
+		return fmt.Errorf("%w (%d bytes)", ErrOutputLimit, e.maxOutput)
+	}
+	return nil
+}
+
+// wrote counts n bytes of output
+func (e *engine) wrote(n int) {
+	e.stats.BytesWritten += int64(n)
+	e.written += int64(n)
+}
@@ -0,0 +1,65 @@
+package command_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestAwk_MaxOutputBytes(t *testing.T) {
+	result := run.Command(command.Awk(LineNumberProgram{}, command.MaxOutputBytes(12))).
+		WithStdinLines("a", "b", "c").Run()
+
+	var re *command.RuntimeError
+	assertion.True(t, errors.As(result.Err, &re), "the run fails with a RuntimeError")
+	assertion.True(t, errors.Is(result.Err, command.ErrOutputLimit), "naming the limit")
+	assertion.Equal(t, re.NR, int64(3), "at the record going past it")
+	assertion.ErrorContains(t, result.Err, "record 3: MaxOutputBytes exceeded (12 bytes)")
+	assertion.Lines(t, result.Stdout, []string{"1: a", "2: b"})
+}
+
+func TestAwk_MaxOutputBytes_Passthrough(t *testing.T) {
+	result := run.Command(command.Awk(command.SimpleProgram{}, command.MaxOutputBytes(4))).
+		WithStdinLines("a", "b", "c").Run()
+
+	assertion.True(t, errors.Is(result.Err, command.ErrOutputLimit), "the fast path is limited too")
+	assertion.Lines(t, result.Stdout, []string{"a", "b"})
+}
+
+func TestAwk_MaxOutputBytes_ActionWriter(t *testing.T) {
+	result := run.Command(command.Awk(ExplodeProgram{}, command.MaxOutputBytes(10))).
+		WithStdinLines("100000 x").Run()
+
+	assertion.True(t, errors.Is(result.Err, command.ErrOutputLimit), "the ActionWriter is stopped")
+	assertion.Equal(t, strings.Join(result.Stdout, "\n"), "x 1\nx 2", "output up to the limit")
+}
+
+func TestAwk_MaxOutputBytes_End(t *testing.T) {
+	result := run.Command(command.Awk(&CountingProgram{}, command.MaxOutputBytes(5))).
+		WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "MaxOutputBytes exceeded")
+	assertion.Equal(t, len(result.Stdout), 0, "no output")
+}
+
+func TestAwk_MaxOutputBytes_MultiPass(t *testing.T) {
+	var total float64
+	var passes []string
+	result := run.Command(command.Awk(PercentProgram{total: &total, passes: &passes},
+		command.EmitAllPasses(true), command.MaxOutputBytes(30))).
+		WithStdinLines("a 1", "b 3").Run()
+
+	assertion.ErrorContains(t, result.Err, "record 2: MaxOutputBytes exceeded (30 bytes)")
+	assertion.Lines(t, result.Stdout, []string{"ignored", "ignored", "1 a 25.0%"})
+}
+
+func TestAwk_MaxOutputBytes_SuppressedPass(t *testing.T) {
+	var total float64
+	var passes []string
+	output := execute(t, PercentProgram{total: &total, passes: &passes}, "a 1\nb 3\n", command.MaxOutputBytes(28))
+	assertion.Equal(t, output, "1 a 25.0%\n2 b 75.0%\ntotal 4\n", "the suppressed pass does not count")
+}
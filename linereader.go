@@ -0,0 +1,99 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// BaseDir is the directory relative names given to ctx.Open are resolved
+// against (default: the working directory)
+type BaseDir string
+
+func (d BaseDir) Configure(flags *flags) { flags.BaseDir = d }
+
+// LineReader reads the lines of an auxiliary file opened with ctx.Open, like
+// awk's getline < "file"
+type LineReader struct {
+	name    string
+	f       *os.File
+	scanner *bufio.Scanner
+	err     error
+
+	// release removes the reader from its Context's table
+	release func()
+}
+
+// Next returns the next line of the file without its terminator, or false at
+// the end of the file or on a read error (see Err)
+func (r *LineReader) Next() (string, bool) {
+	if r.scanner == nil || !r.scanner.Scan() {
+		if r.scanner != nil {
+			r.err = r.scanner.Err()
+		}
+		return "", false
+	}
+	return r.scanner.Text(), true
+}
+
+// Err returns the error that stopped Next, if any
+func (r *LineReader) Err() error {
+	return r.err
+}
+
+// Close closes the file; opening the same name again starts from its first line
+func (r *LineReader) Close() error {
+	if r.f == nil {
+		return nil
+	}
+	r.release()
+	err := r.f.Close()
+	r.f, r.scanner = nil, nil
+	return err
+}
+
+// Open returns a reader over the lines of the named file, relative to BaseDir.
+// As in awk, the file stays open, and opening the same name again returns the
+// same reader, until it is closed; files still open are closed after End.
+func (c *Context) Open(name string) (*LineReader, error) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if r, ok := c.readers[name]; ok {
+		return r, nil
+	}
+	path := name
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(c.baseDir, path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	if c.readers == nil {
+		c.readers = make(map[string]*LineReader)
+	}
+	r := &LineReader{name: name, f: f, scanner: bufio.NewScanner(f)}
+	r.release = func() {
+		if c.mu != nil {
+			c.mu.Lock()
+			defer c.mu.Unlock()
+		}
+		delete(c.readers, name)
+	}
+	c.readers[name] = r
+	return r, nil
+}
+
+// closeReaders closes the files the Program left open
+func (c *Context) closeReaders() error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(c.readers)) {
+		errs = append(errs, c.readers[name].Close())
+	}
+	return errors.Join(errs...)
+}
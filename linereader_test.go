@@ -0,0 +1,103 @@
+package command_test
+
+import (
+	"errors"
+	"io/fs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// EnrichProgram replaces the user id in $1 with the name from users.txt,
+// loaded when the first record needs it
+type EnrichProgram struct {
+	command.SimpleProgram
+	names  map[string]string
+	reader *command.LineReader
+}
+
+func (p *EnrichProgram) Action(ctx *command.Context) (string, bool) {
+	if p.names == nil {
+		r, err := ctx.Open("users.txt")
+		if err != nil {
+			ctx.Abort(err)
+			return "", false
+		}
+		p.reader, p.names = r, make(map[string]string)
+		for line, ok := r.Next(); ok; line, ok = r.Next() {
+			id, name, _ := strings.Cut(line, ":")
+			p.names[id] = name
+		}
+		if err := r.Err(); err != nil {
+			ctx.Abort(err)
+		}
+	}
+	ctx.SetField(1, p.names[ctx.Field(1)])
+	return ctx.Print(ctx.Field(1), ctx.Field(2)), true
+}
+
+func TestContext_Open(t *testing.T) {
+	users := writeFile(t, "users.txt", "1:alice", "2:bob")
+	prog := &EnrichProgram{}
+	result := run.Command(command.Awk(prog, command.BaseDir(filepath.Dir(users)))).
+		WithStdinLines("2 login", "1 logout").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"bob login", "alice logout"})
+	_, ok := prog.reader.Next()
+	assertion.False(t, ok, "files left open are closed after End")
+}
+
+func TestContext_Open_Missing(t *testing.T) {
+	result := run.Command(command.Awk(&EnrichProgram{}, command.BaseDir(t.TempDir()))).
+		WithStdinLines("1 login").Run()
+
+	assertion.Error(t, result.Err)
+	assertion.True(t, errors.Is(result.Err, fs.ErrNotExist), "the open error is returned")
+}
+
+// GetlineProgram reads one line of words.txt per record, like
+// getline w < "words.txt"
+type GetlineProgram struct {
+	command.SimpleProgram
+}
+
+func (p GetlineProgram) Action(ctx *command.Context) (string, bool) {
+	r, err := ctx.Open("words.txt")
+	if err != nil {
+		ctx.Abort(err)
+		return "", false
+	}
+	word, ok := r.Next()
+	if !ok {
+		// Start over on the next record
+		_ = r.Close()
+		word = "-"
+	}
+	return ctx.Print(ctx.Field(0), word), true
+}
+
+func TestContext_Open_SameHandle(t *testing.T) {
+	words := writeFile(t, "words.txt", "one", "two")
+	result := run.Command(command.Awk(GetlineProgram{}, command.BaseDir(filepath.Dir(words)))).
+		WithStdinLines("a", "b", "c", "d").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a one", "b two", "c -", "d one"})
+}
+
+func TestContext_Open_Absolute(t *testing.T) {
+	words := writeFile(t, "words.txt", "one")
+	ctx := &command.Context{}
+	r, err := ctx.Open(words)
+	assertion.NoError(t, err)
+	line, ok := r.Next()
+	assertion.True(t, ok, "first line")
+	assertion.Equal(t, line, "one", "absolute paths ignore BaseDir")
+	assertion.NoError(t, r.Close())
+	assertion.NoError(t, r.Close())
+}
@@ -0,0 +1,42 @@
+package command
+
+// Lookback keeps the last n records the Program has seen, for ctx.Prev
+type Lookback int
+
+func (n Lookback) Configure(flags *flags) { flags.Lookback = n }
+
+// lookback is a ring of the most recent records
+type lookback struct {
+	records []*Record
+	next    int // oldest record once the ring is full
+}
+
+func newLookback(n Lookback) *lookback {
+	if n <= 0 {
+		return nil
+	}
+	return &lookback{records: make([]*Record, 0, n)}
+}
+
+// push adds a record, dropping the oldest one when the ring is full
+func (l *lookback) push(r *Record) {
+	if len(l.records) < cap(l.records) {
+		l.records = append(l.records, r)
+		return
+	}
+	l.records[l.next] = r
+	l.next = (l.next + 1) % len(l.records)
+}
+
+// Prev returns the k-th record before the current one (Prev(1) is the
+// previous record), as the Program saw it before Action changed it, or nil
+// when there is no such record or it is more than Lookback records back. In
+// End, Prev(1) is the last record. Without Lookback it always returns nil.
+func (c *Context) Prev(k int) *Record {
+	l := c.lookback
+	if l == nil || k < 1 || k > len(l.records) {
+		return nil
+	}
+	// The most recent record is the one before next
+	return l.records[(l.next-k+len(l.records))%len(l.records)]
+}
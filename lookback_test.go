@@ -0,0 +1,118 @@
+package command_test
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// DeltaProgram prints each record with the change of $2 since the previous
+// record, like awk 'NR > 1 { print $1, $2 - prev } { prev = $2 }', and the
+// records Lookback kept in End
+type DeltaProgram struct {
+	command.SimpleProgram
+}
+
+func (p DeltaProgram) Action(ctx *command.Context) (string, bool) {
+	prev := ctx.Prev(1)
+	if prev == nil {
+		return ctx.Field(1) + " -", true
+	}
+	now, _ := ctx.FieldInt(2)
+	before, _ := strconv.ParseInt(prev.Field(2), 10, 64)
+	// Prev keeps the record as read, whatever Action does to it
+	ctx.SetField(2, "changed")
+	return fmt.Sprintf("%s %+d (NR %d: %s)", ctx.Field(1), now-before, prev.NR(), prev.Text()), true
+}
+
+func (p DeltaProgram) End(ctx *command.Context) (string, error) {
+	var kept []string
+	for k := 1; ctx.Prev(k) != nil; k++ {
+		kept = append(kept, ctx.Prev(k).Field(1))
+	}
+	return fmt.Sprint("kept ", kept), nil
+}
+
+func TestAwk_Lookback(t *testing.T) {
+	result := run.Command(command.Awk(DeltaProgram{}, command.Lookback(2))).
+		WithStdinLines("a 10", "b 15", "c 12", "d 12").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"a -",
+		"b +5 (NR 1: a 10)",
+		"c -3 (NR 2: b 15)",
+		"d +0 (NR 3: c 12)",
+		"kept [d c]",
+	})
+}
+
+func TestAwk_Lookback_Off(t *testing.T) {
+	result := run.Command(command.Awk(DeltaProgram{})).WithStdinLines("a 1", "b 2").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a -", "b -", "kept []"})
+}
+
+// WindowProgram checks that Prev never reaches more than Lookback records back
+type WindowProgram struct {
+	command.SimpleProgram
+	n int
+}
+
+func (p WindowProgram) Action(ctx *command.Context) (string, bool) {
+	for k := 1; k <= p.n; k++ {
+		want := ctx.NR - int64(k)
+		if r := ctx.Prev(k); (r == nil) != (want < 1) || (r != nil && r.NR() != want) {
+			return fmt.Sprintf("NR %d: Prev(%d) is %v", ctx.NR, k, r), true
+		}
+	}
+	if ctx.Prev(p.n+1) != nil || ctx.Prev(0) != nil || ctx.Prev(-1) != nil {
+		return fmt.Sprintf("NR %d: Prev out of the window", ctx.NR), true
+	}
+	return "", false
+}
+
+func TestAwk_Lookback_Window(t *testing.T) {
+	lines := make([]string, 1000)
+	for i := range lines {
+		lines[i] = fmt.Sprint(i)
+	}
+	result := run.Command(command.Awk(WindowProgram{n: 3}, command.Lookback(3))).WithStdinLines(lines...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Empty(t, result.Stdout)
+}
+
+func TestAwkE_Lookback_Invalid(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.Lookback(0))
+
+	assertion.ErrorContains(t, err, "invalid Lookback 0: must be positive")
+}
+
+// FileChangeProgram prints the position of the previous record when the input changes
+type FileChangeProgram struct {
+	command.SimpleProgram
+}
+
+func (p FileChangeProgram) Action(ctx *command.Context) (string, bool) {
+	prev := ctx.Prev(1)
+	if prev == nil || prev.Filename() == ctx.Filename {
+		return "", false
+	}
+	return fmt.Sprintf("%s ended at FNR %d", filepath.Base(prev.Filename()), prev.FNR()), true
+}
+
+func TestAwk_Lookback_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "a", "b", "c")
+	second := writeFile(t, "second.txt", "d")
+
+	result := run.Command(command.Awk(FileChangeProgram{}, command.Lookback(1), first, second)).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"first.txt ended at FNR 3"})
+}
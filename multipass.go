@@ -0,0 +1,115 @@
+package command
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// MultiPass is implemented by Programs that read their input more than once,
+// e.g. to compute a total before printing each value as a share of it.
+// Begin and End run once; BeginPass runs before each pass (numbered from 1),
+// which ctx.Pass also reports. NR starts over on each pass and only the last
+// pass outputs records, unless EmitAllPasses is set. Statistics describe the
+// last pass.
+//
+// Files are read again on each pass; stdin and Sources are spooled while the
+// first pass reads them, in memory up to SpoolLimit bytes and in a temporary
+// file beyond.
+type MultiPass interface {
+	Passes() int
+	BeginPass(ctx *Context, pass int) error
+}
+
+// defaultSpoolLimit is the default SpoolLimit
+const defaultSpoolLimit = 32 << 20
+
+// beginPass prepares the engine for the current pass and calls BeginPass
+func (e *engine) beginPass(mp MultiPass) (err error) {
+	defer e.catch(&err)
+	e.ctx.Pass = e.pass
+	e.ctx.NR = e.startNR
+	e.stats = Stats{}
+	e.suppress = e.pass < e.passes && !e.emitAll
+	if e.tail != nil {
+		e.tail = newTailRing(TailRecords(cap(e.tail.records)))
+	}
+	if e.ctx.lookback != nil {
+		e.ctx.lookback = newLookback(Lookback(cap(e.ctx.lookback.records)))
+	}
+	e.calling = "BeginPass"
+	err = mp.BeginPass(e.ctx, e.pass)
+	e.calling = ""
+	if err != nil {
+		return e.errorf("pass %d: %w", e.pass, err)
+	}
+	if err := e.ctx.aborted(); err != nil {
+		return e.errorf("pass %d: %w", e.pass, err)
+	}
+	return nil
+}
+
+// rewind returns the reader of an input that cannot be opened again, keyed
+// by its position: on the first pass r, spooled as it is read, and on the
+// following passes the spooled copy
+func (e *engine) rewind(key int, r io.Reader) io.Reader {
+	if e.passes <= 1 {
+		return r
+	}
+	if e.pass == 1 {
+		s := &spool{limit: e.spoolLimit}
+		e.spools[key] = s
+		return io.TeeReader(r, s)
+	}
+	if s, ok := e.spools[key]; ok {
+		return s.reader()
+	}
+	return bytes.NewReader(nil)
+}
+
+// spool keeps a copy of an input, in memory up to limit bytes and in a
+// temporary file beyond
+type spool struct {
+	limit int64
+	mem   bytes.Buffer
+	file  *os.File
+	size  int64 // bytes written to file
+}
+
+func (s *spool) Write(p []byte) (int, error) {
+	if s.file == nil && int64(s.mem.Len()+len(p)) > s.limit {
+		f, err := os.CreateTemp("", "awk-spool-*")
+		if err != nil {
+			return 0, err
+		}
+		s.file = f
+		n, err := f.Write(s.mem.Bytes())
+		s.size = int64(n)
+		if err != nil {
+			return 0, err
+		}
+		s.mem = bytes.Buffer{}
+	}
+	if s.file == nil {
+		return s.mem.Write(p)
+	}
+	n, err := s.file.Write(p)
+	s.size += int64(n)
+	return n, err
+}
+
+// reader returns a reader of the spooled input from its start
+func (s *spool) reader() io.Reader {
+	if s.file != nil {
+		return io.NewSectionReader(s.file, 0, s.size)
+	}
+	return bytes.NewReader(s.mem.Bytes())
+}
+
+// close removes the temporary file, if any
+func (s *spool) close() {
+	if s.file != nil {
+		s.file.Close()
+		os.Remove(s.file.Name())
+	}
+}
@@ -0,0 +1,94 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// PercentProgram prints each $2 as a percentage of the total of $2
+type PercentProgram struct {
+	command.SimpleProgram
+	total  *float64
+	passes *[]string
+}
+
+func (p PercentProgram) Passes() int { return 2 }
+
+func (p PercentProgram) BeginPass(ctx *command.Context, pass int) error {
+	*p.passes = append(*p.passes, fmt.Sprintf("pass %d NR=%d", pass, ctx.NR))
+	return nil
+}
+
+func (p PercentProgram) Action(ctx *command.Context) (string, bool) {
+	value, _ := strconv.ParseFloat(ctx.Field(2), 64)
+	if ctx.Pass == 1 {
+		*p.total += value
+		return "ignored", true
+	}
+	return fmt.Sprintf("%d %s %.1f%%", ctx.NR, ctx.Field(1), 100*value / *p.total), true
+}
+
+func (p PercentProgram) End(ctx *command.Context) (string, error) {
+	return ctx.Print("total", *p.total), nil
+}
+
+func TestAwk_MultiPass_Stdin(t *testing.T) {
+	var total float64
+	var passes []string
+	var stats command.Stats
+	result := run.Command(command.Awk(PercentProgram{total: &total, passes: &passes},
+		command.SpoolLimit(8), command.StatsRecipient(&stats))).
+		WithStdinLines("a 1", "b 3", "c 4").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1 a 12.5%", "2 b 37.5%", "3 c 50.0%", "total 8"})
+	assertion.Equal(t, strings.Join(passes, ", "), "pass 1 NR=0, pass 2 NR=0", "BeginPass runs before every pass")
+	assertion.Equal(t, stats.Emitted, int64(4), "statistics describe the last pass")
+}
+
+func TestAwk_MultiPass_Memory(t *testing.T) {
+	var total float64
+	var passes []string
+	output := execute(t, PercentProgram{total: &total, passes: &passes}, "a 1\nb 1\n")
+	assertion.Equal(t, output, "1 a 50.0%\n2 b 50.0%\ntotal 2\n", "spooled in memory")
+}
+
+func TestAwk_MultiPass_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "a 2")
+	second := writeFile(t, "second.txt", "b 6")
+
+	var total float64
+	var passes []string
+	result := run.Command(command.Awk(PercentProgram{total: &total, passes: &passes}, first, "-", second)).
+		WithStdinLines("c 2").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1 a 20.0%", "2 c 20.0%", "3 b 60.0%", "total 10"})
+}
+
+func TestAwk_MultiPass_Sources(t *testing.T) {
+	var total float64
+	var passes []string
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(PercentProgram{total: &total, passes: &passes},
+		command.InputReaders(strings.NewReader("a 1\n"), strings.NewReader("b 1\n"))).
+		Executor()(context.Background(), strings.NewReader(""), &stdout, &stderr)
+
+	assertion.NoError(t, err)
+	assertion.Equal(t, stdout.String(), "1 a 50.0%\n2 b 50.0%\ntotal 2\n", "sources are spooled")
+}
+
+func TestAwk_MultiPass_EmitAllPasses(t *testing.T) {
+	var total float64
+	var passes []string
+	output := execute(t, PercentProgram{total: &total, passes: &passes}, "a 1\n", command.EmitAllPasses(true))
+	assertion.Equal(t, output, "ignored\n1 a 100.0%\ntotal 1\n", "every pass outputs")
+}
@@ -0,0 +1,142 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Observer receives the events of a run, for progress reporting and health
+// checks in long pipelines. Embed NopObserver to implement only some of them.
+// A panic in an Observer is reported on stderr and the Observer is dropped;
+// the run goes on.
+type Observer interface {
+	// OnStart is called once before Begin
+	OnStart(info RunInfo)
+
+	// OnFileStart and OnFileEnd are called around every input, stdin included
+	OnFileStart(name string)
+	OnFileEnd(name string, records int64)
+
+	// OnRecord is called every n records read, with NR
+	OnRecord(nr int64)
+
+	// OnEmit is called every n records output, with the number so far
+	OnEmit(count int64)
+
+	// OnFinish is called once the run is over, however it ended
+	OnFinish(stats Stats, err error)
+}
+
+// RunInfo describes a run to Observer.OnStart
+type RunInfo struct {
+	// Stage names the Program inside a Pipe ("stage 1"), or is empty
+	Stage string
+
+	// Started is when the run began
+	Started time.Time
+}
+
+// NopObserver implements every Observer method as a no-op
+type NopObserver struct{}
+
+func (NopObserver) OnStart(info RunInfo)                 {}
+func (NopObserver) OnFileStart(name string)              {}
+func (NopObserver) OnFileEnd(name string, records int64) {}
+func (NopObserver) OnRecord(nr int64)                    {}
+func (NopObserver) OnEmit(count int64)                   {}
+func (NopObserver) OnFinish(stats Stats, err error)      {}
+
+type observe struct {
+	observer Observer
+	every    int64
+}
+
+// Observe reports the events of the run to o, calling OnRecord and OnEmit
+// every n records (every record when n <= 1). In a Pipe, both stages report
+// to o unless it is given to a Stage.
+func Observe(o Observer, n int64) observe { return observe{observer: o, every: max(n, 1)} }
+
+func (o observe) Configure(flags *flags) { flags.Observer = o }
+
+// observe calls event with the Observer, if any, dropping it if it panics
+func (e *engine) observe(method string, event func(o Observer)) {
+	if e.observer.observer == nil {
+		return
+	}
+	defer func() {
+		if v := recover(); v != nil {
+			e.observer.observer = nil
+			if e.stderr != nil {
+				fmt.Fprintf(e.stderr, "awk: %sobserver panic in %s: %v\n", e.stagePrefix(), method, v)
+			}
+		}
+	}()
+	event(e.observer.observer)
+}
+
+// observeRecord tells the Observer about every n-th record read
+func (e *engine) observeRecord() {
+	if e.observer.observer != nil && e.stats.Records%e.observer.every == 0 {
+		nr := e.ctx.NR
+		e.observe("OnRecord", func(o Observer) { o.OnRecord(nr) })
+	}
+}
+
+// observeEmit counts a record output and tells the Observer about every n-th
+func (e *engine) observeEmit() {
+	e.stats.Emitted++
+	if e.observer.observer != nil && e.stats.Emitted%e.observer.every == 0 {
+		count := e.stats.Emitted
+		e.observe("OnEmit", func(o Observer) { o.OnEmit(count) })
+	}
+}
+
+// stagePrefix returns the stage followed by ": ", or "" outside of a Pipe
+func (e *engine) stagePrefix() string {
+	if e.stage == "" {
+		return ""
+	}
+	return e.stage + ": "
+}
+
+type progress struct {
+	NopObserver
+	w    io.Writer
+	info RunInfo
+	file string
+}
+
+// Progress returns an Observer writing a line to w every time it is told
+// about records, e.g. with Observe(Progress(os.Stderr), 100000):
+//
+//	awk: NR=100000 file=access.log elapsed=1.2s
+//
+// and a last line with the totals when the run is over.
+func Progress(w io.Writer) Observer { return &progress{w: w} }
+
+func (p *progress) OnStart(info RunInfo)    { p.info = info }
+func (p *progress) OnFileStart(name string) { p.file = name }
+
+func (p *progress) OnRecord(nr int64) {
+	fmt.Fprintf(p.w, "awk: %sNR=%d file=%s elapsed=%s\n", p.prefix(), nr, p.file, p.elapsed())
+}
+
+func (p *progress) OnFinish(stats Stats, err error) {
+	status := "done"
+	if err != nil {
+		status = "failed"
+	}
+	fmt.Fprintf(p.w, "awk: %s%s records=%d emitted=%d elapsed=%s\n", p.prefix(), status, stats.Records, stats.Emitted, p.elapsed())
+}
+
+func (p *progress) prefix() string {
+	if p.info.Stage == "" {
+		return ""
+	}
+	return p.info.Stage + ": "
+}
+
+func (p *progress) elapsed() time.Duration {
+	return time.Since(p.info.Started).Round(time.Millisecond)
+}
@@ -0,0 +1,111 @@
+package command_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// recordingObserver logs the events it receives
+type recordingObserver struct {
+	command.NopObserver
+	log []string
+}
+
+func (r *recordingObserver) OnStart(info command.RunInfo) {
+	r.log = append(r.log, fmt.Sprintf("start %q", info.Stage))
+}
+
+func (r *recordingObserver) OnFileStart(name string) {
+	r.log = append(r.log, "file "+name)
+}
+
+func (r *recordingObserver) OnFileEnd(name string, records int64) {
+	r.log = append(r.log, fmt.Sprintf("end %s %d", name, records))
+}
+
+func (r *recordingObserver) OnRecord(nr int64) {
+	r.log = append(r.log, fmt.Sprintf("record %d", nr))
+}
+
+func (r *recordingObserver) OnEmit(count int64) {
+	r.log = append(r.log, fmt.Sprintf("emit %d", count))
+}
+
+func (r *recordingObserver) OnFinish(stats command.Stats, err error) {
+	r.log = append(r.log, fmt.Sprintf("finish %d %d %v", stats.Records, stats.Emitted, err))
+}
+
+func TestAwk_Observe(t *testing.T) {
+	a, b := writeFile(t, "a.txt", "1", "2", "3"), writeFile(t, "b.txt", "4")
+	var o recordingObserver
+	result := run.Command(command.Awk(command.SimpleProgram{}, a, b, command.Observe(&o, 2))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1", "2", "3", "4"})
+	assertion.Lines(t, o.log, []string{
+		`start ""`,
+		"file " + a, "record 2", "emit 2", "end " + a + " 3",
+		"file " + b, "record 4", "emit 4", "end " + b + " 1",
+		"finish 4 4 <nil>",
+	})
+}
+
+func TestAwk_Observe_Error(t *testing.T) {
+	var ends int
+	var o recordingObserver
+	result := run.Command(command.Awk(AbortProgram{ends: &ends}, command.Observe(&o, 10))).
+		WithStdinLines("a", "bad").Run()
+
+	assertion.ErrorContains(t, result.Err, "corrupt checksum")
+	assertion.Contains(t, o.log, "finish 2 1 record 2: corrupt checksum")
+}
+
+func TestPipe_Observe(t *testing.T) {
+	var o recordingObserver
+	result := run.Command(command.Pipe(command.SimpleProgram{}, LineNumberProgram{}, command.Observe(&o, 1))).
+		WithStdinLines("a").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Contains(t, o.log, `start "stage 1"`)
+	assertion.Contains(t, o.log, `start "stage 2"`)
+}
+
+// panickingObserver panics on every record
+type panickingObserver struct {
+	command.NopObserver
+}
+
+func (panickingObserver) OnRecord(nr int64) { panic("observer bug") }
+
+func TestAwk_Observe_Panic(t *testing.T) {
+	result := run.Command(command.Awk(LineNumberProgram{}, command.Observe(panickingObserver{}, 1))).
+		WithStdinLines("a", "b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: a", "2: b"})
+	assertion.Lines(t, result.Stderr, []string{"awk: observer panic in OnRecord: observer bug"})
+}
+
+func TestAwkE_Observe_Nil(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.Observe(nil, 1))
+	assertion.ErrorContains(t, err, "invalid Observe: nil Observer")
+}
+
+func TestProgress(t *testing.T) {
+	var buf bytes.Buffer
+	path := writeFile(t, "data.txt", "1", "2", "3", "4", "5")
+	result := run.Command(command.Awk(command.SimpleProgram{}, path, command.Observe(command.Progress(&buf), 2))).Run()
+
+	assertion.NoError(t, result.Err)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	assertion.Count(t, lines, 3)
+	assertion.True(t, strings.HasPrefix(lines[0], "awk: NR=2 file="+path+" elapsed="), lines[0])
+	assertion.True(t, strings.HasPrefix(lines[1], "awk: NR=4 file="+path+" elapsed="), lines[1])
+	assertion.True(t, strings.HasPrefix(lines[2], "awk: done records=5 emitted=5 elapsed="), lines[2])
+}
@@ -1,7 +1,29 @@
 package command
 
+import (
+	"fmt"
+	"io"
+)
+
 type FieldSeparator string
 type OutputFieldSeparator string
+type OutputRecordSeparator string
+type RecordSeparator string
+type OutputFormat string
+type Environment map[string]string
+type StartNR int64
+type SharedVariables bool
+type BytesMode bool
+type TraceEvery int64
+type ReadBufferSize int
+type WriteBufferSize int
+type MaxRecordSize int
+type NoSniff bool
+type TailRecords int
+type SpoolLimit int64
+type EmitAllPasses bool
+type InputEncoding string
+type OutputEncoding string
 
 type Variable struct {
 	Name  string
@@ -9,16 +31,139 @@ type Variable struct {
 }
 
 type flags struct {
-	FieldSeparator       FieldSeparator
-	OutputFieldSeparator OutputFieldSeparator
-	Variables            map[string]any
+	FieldSeparator        FieldSeparator
+	OutputFieldSeparator  OutputFieldSeparator
+	OutputRecordSeparator OutputRecordSeparator
+	RecordSeparator       RecordSeparator
+	OutputFormat          OutputFormat
+	Variables             map[string]any
+	Environment           Environment
+	StartNR               StartNR
+	SharedVariables       SharedVariables
+	BytesMode             BytesMode
+	ResetPerFile          resetPerFile
+	StatsRecipient        *Stats
+	TraceEvery            TraceEvery
+	ReadBufferSize        ReadBufferSize
+	WriteBufferSize       WriteBufferSize
+	MaxRecordSize         MaxRecordSize
+	NoSniff               NoSniff
+	Decompressors         []Decompressor
+	Sources               Sources
+	InputEncoding         InputEncoding
+	OutputEncoding        OutputEncoding
+	InputDecoder          InputDecoder
+	OutputEncoder         OutputEncoder
+	PreserveTerminators   preserveTerminators
+	RecordSplitter        RecordSplitter
+	KeepTerminator        keepTerminator
+	TailRecords           TailRecords
+	SpoolLimit            SpoolLimit
+	EmitAllPasses         EmitAllPasses
+	NamedOutputs          map[string]io.Writer
+	OutputFiles           OutputFiles
+	BaseDir               BaseDir
+	RecordSource          RecordSource
+	RecordChannel         <-chan string
+	EmitFunc              emitFunc
+	EmitChannel           emitChannel
+	NoRecover             NoRecover
+	FatalFileErrors       FatalFileErrors
+	Glob                  Glob
+	NullGlob              NullGlob
+	DumpVariables         dumpVariables
+	RegexpCacheSize       RegexpCacheSize
+	MaxOutputBytes        MaxOutputBytes
+	Observer              observe
+	Lookback              Lookback
+	ErrorfFails           ErrorfFails
 }
 
-func (f FieldSeparator) Configure(flags *flags)       { flags.FieldSeparator = f }
-func (o OutputFieldSeparator) Configure(flags *flags) { flags.OutputFieldSeparator = o }
+func (f FieldSeparator) Configure(flags *flags)        { flags.FieldSeparator = f }
+func (o OutputFieldSeparator) Configure(flags *flags)  { flags.OutputFieldSeparator = o }
+func (o OutputRecordSeparator) Configure(flags *flags) { flags.OutputRecordSeparator = o }
+func (r RecordSeparator) Configure(flags *flags)       { flags.RecordSeparator = r }
+func (o OutputFormat) Configure(flags *flags)          { flags.OutputFormat = o }
+func (e Environment) Configure(flags *flags)           { flags.Environment = e }
+func (n StartNR) Configure(flags *flags)               { flags.StartNR = n }
+func (s SharedVariables) Configure(flags *flags)       { flags.SharedVariables = s }
+func (b BytesMode) Configure(flags *flags)             { flags.BytesMode = b }
+func (t TraceEvery) Configure(flags *flags)            { flags.TraceEvery = t }
+func (n ReadBufferSize) Configure(flags *flags)        { flags.ReadBufferSize = n }
+func (n WriteBufferSize) Configure(flags *flags)       { flags.WriteBufferSize = n }
+func (n MaxRecordSize) Configure(flags *flags)         { flags.MaxRecordSize = n }
+func (n NoSniff) Configure(flags *flags)               { flags.NoSniff = n }
+func (n TailRecords) Configure(flags *flags)           { flags.TailRecords = n }
+func (n SpoolLimit) Configure(flags *flags)            { flags.SpoolLimit = n }
+func (e EmitAllPasses) Configure(flags *flags)         { flags.EmitAllPasses = e }
+func (e InputEncoding) Configure(flags *flags)         { flags.InputEncoding = e }
+func (e OutputEncoding) Configure(flags *flags)        { flags.OutputEncoding = e }
+
+// Configure sets the variable, or the setting named by one of awk's built-in
+// variables FS, OFS, RS, ORS and OFMT, as awk -v does. NR and NF belong to
+// the engine and are rejected.
 func (v Variable) Configure(flags *flags) {
+	switch v.Name {
+	case "FS":
+		flags.FieldSeparator = FieldSeparator(fmt.Sprint(v.Value))
+		return
+	case "OFS":
+		flags.OutputFieldSeparator = OutputFieldSeparator(fmt.Sprint(v.Value))
+		return
+	case "RS":
+		flags.RecordSeparator = RecordSeparator(fmt.Sprint(v.Value))
+		return
+	case "ORS":
+		flags.OutputRecordSeparator = OutputRecordSeparator(fmt.Sprint(v.Value))
+		return
+	case "OFMT":
+		flags.OutputFormat = OutputFormat(fmt.Sprint(v.Value))
+		return
+	}
 	if flags.Variables == nil {
 		flags.Variables = make(map[string]any)
 	}
 	flags.Variables[v.Name] = v.Value
 }
+
+type statsRecipient struct{ stats *Stats }
+
+// StatsRecipient makes the command store its run statistics in s when it
+// finishes, however it ends. In a Pipe, give each Stage its own recipient.
+func StatsRecipient(s *Stats) statsRecipient { return statsRecipient{s} }
+
+func (s statsRecipient) Configure(flags *flags) { flags.StatsRecipient = s.stats }
+
+// Trace writes a debugging line to stderr for every record, and for BEGIN and
+// END; TraceEvery(n) samples every n-th record only
+func Trace() TraceEvery { return 1 }
+
+type resetPerFile bool
+
+// ResetPerFile resets the state between input files: variables return to
+// their initial values, the ready-made Programs start over and Programs
+// implementing Resetter have Reset called. End still runs once, after the
+// last file.
+func ResetPerFile() resetPerFile { return true }
+
+func (r resetPerFile) Configure(flags *flags) { flags.ResetPerFile = r }
+
+type preserveTerminators bool
+
+// PreserveTerminators terminates every record Action outputs with the input
+// record's exact terminator (ctx.RT): "\r\n" stays "\r\n" and an unterminated
+// last record stays unterminated. Output from End still ends with ORS.
+func PreserveTerminators() preserveTerminators { return true }
+
+func (p preserveTerminators) Configure(flags *flags) { flags.PreserveTerminators = p }
+
+type keepTerminator bool
+
+// KeepTerminator includes each record's terminator (ctx.RT) at the end of $0,
+// while fields are still split from the text without it. Action output that
+// ends with the record's terminator, or is the unterminated last record
+// unchanged, is written without ORS, so a pass-through Program reproduces its
+// input; any other output is terminated with ORS.
+func KeepTerminator() keepTerminator { return true }
+
+func (k keepTerminator) Configure(flags *flags) { flags.KeepTerminator = k }
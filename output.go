@@ -0,0 +1,118 @@
+package command
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// OutputFiles makes ctx.EmitTo write names not registered with NamedOutput
+// to files of that name under the directory, like awk's print > "file". A
+// file is created (truncated) when first written to, kept open for the rest
+// of the run and closed after End.
+type OutputFiles string
+
+func (d OutputFiles) Configure(flags *flags) { flags.OutputFiles = d }
+
+type namedOutput struct {
+	name string
+	w    io.Writer
+}
+
+// NamedOutput registers w as the output ctx.EmitTo writes to under name.
+// The command never closes w.
+func NamedOutput(name string, w io.Writer) namedOutput { return namedOutput{name, w} }
+
+func (o namedOutput) Configure(flags *flags) {
+	if flags.NamedOutputs == nil {
+		flags.NamedOutputs = make(map[string]io.Writer)
+	}
+	flags.NamedOutputs[o.name] = o.w
+}
+
+// ErrUnknownOutput is returned by ctx.EmitTo for a name that is neither
+// registered with NamedOutput nor allowed by OutputFiles
+var ErrUnknownOutput = errors.New("unknown output")
+
+// EmitTo writes values formatted like Print, terminated with ORS, to the
+// output registered under name, or to the file name under the OutputFiles
+// directory. Records written to named outputs are not counted in Stats.
+func (c *Context) EmitTo(name string, values ...any) error {
+	if c.emitTo == nil {
+		return errNoOutput
+	}
+	return c.emitTo(name, c.Print(values...), c.ORS)
+}
+
+// outputs are the named destinations of a run
+type outputs struct {
+	named map[string]io.Writer
+	dir   OutputFiles
+	files map[string]*outputFile
+}
+
+// outputFile is a file opened under the OutputFiles directory
+type outputFile struct {
+	f *os.File
+	w *bufio.Writer
+}
+
+func newOutputs(f flags) *outputs {
+	return &outputs{named: f.NamedOutputs, dir: f.OutputFiles, files: make(map[string]*outputFile)}
+}
+
+// writer returns the output named name, creating its file if needed
+func (o *outputs) writer(name string) (io.Writer, error) {
+	if w, ok := o.named[name]; ok {
+		return w, nil
+	}
+	if file, ok := o.files[name]; ok {
+		return file.w, nil
+	}
+	if o.dir == "" {
+		return nil, fmt.Errorf("%w %q", ErrUnknownOutput, name)
+	}
+	if !filepath.IsLocal(name) {
+		return nil, fmt.Errorf("%w %q: not a path inside %s", ErrUnknownOutput, name, o.dir)
+	}
+	path := filepath.Join(string(o.dir), name)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	file := &outputFile{f: f, w: bufio.NewWriter(f)}
+	o.files[name] = file
+	return file.w, nil
+}
+
+// close flushes and closes the files opened so far
+func (o *outputs) close() error {
+	var errs []error
+	for _, name := range slices.Sorted(maps.Keys(o.files)) {
+		file := o.files[name]
+		errs = append(errs, file.w.Flush(), file.f.Close())
+		delete(o.files, name)
+	}
+	return errors.Join(errs...)
+}
+
+// emitTo writes a record produced by the Program to the output named name
+func (e *engine) emitTo(name, record, terminator string) error {
+	if e.suppress {
+		return nil
+	}
+	w, err := e.outputs.writer(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, record+terminator)
+	return err
+}
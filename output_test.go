@@ -0,0 +1,129 @@
+package command_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// StatusSplitProgram writes every request to the output named after the
+// class of its status code ($2), and counts them on stdout
+type StatusSplitProgram struct {
+	command.SimpleProgram
+}
+
+func (p StatusSplitProgram) Action(ctx *command.Context) (string, bool) {
+	class := ctx.Field(2)[:1] + "xx"
+	if err := ctx.EmitTo(class, ctx.Field(1), ctx.Field(2)); err != nil {
+		ctx.Abort(err)
+	}
+	return "", false
+}
+
+func (p StatusSplitProgram) End(ctx *command.Context) (string, error) {
+	return ctx.Print("requests", ctx.NR), nil
+}
+
+var requests = []string{"/ 200", "/login 302", "/admin 403", "/api 500", "/about 200"}
+
+func TestContext_EmitTo(t *testing.T) {
+	var ok, redirects, errs bytes.Buffer
+	result := run.Command(command.Awk(StatusSplitProgram{},
+		command.NamedOutput("2xx", &ok),
+		command.NamedOutput("3xx", &redirects),
+		command.NamedOutput("4xx", &errs),
+		command.NamedOutput("5xx", &errs),
+	)).WithStdinLines(requests...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"requests 5"})
+	assertion.Equal(t, ok.String(), "/ 200\n/about 200\n", "2xx")
+	assertion.Equal(t, redirects.String(), "/login 302\n", "3xx")
+	assertion.Equal(t, errs.String(), "/admin 403\n/api 500\n", "4xx and 5xx share a writer")
+}
+
+func TestContext_EmitTo_Unknown(t *testing.T) {
+	var ok bytes.Buffer
+	result := run.Command(command.Awk(StatusSplitProgram{}, command.NamedOutput("2xx", &ok))).
+		WithStdinLines(requests...).Run()
+
+	assertion.Error(t, result.Err)
+	assertion.True(t, errors.Is(result.Err, command.ErrUnknownOutput), "unknown names fail")
+	assertion.ErrorContains(t, result.Err, `record 2: unknown output "3xx"`)
+}
+
+func TestContext_EmitTo_OutputFiles(t *testing.T) {
+	dir := t.TempDir()
+	var redirects bytes.Buffer
+	result := run.Command(command.Awk(StatusSplitProgram{},
+		command.OutputFiles(dir), command.NamedOutput("3xx", &redirects))).
+		WithStdinLines(requests...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, redirects.String(), "/login 302\n", "registered names take precedence")
+	for name, want := range map[string]string{
+		"2xx": "/ 200\n/about 200\n",
+		"4xx": "/admin 403\n",
+		"5xx": "/api 500\n",
+	} {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		assertion.NoError(t, err)
+		assertion.Equal(t, string(data), want, name)
+	}
+	_, err := os.Stat(filepath.Join(dir, "3xx"))
+	assertion.True(t, os.IsNotExist(err), "no file for a registered name")
+}
+
+func TestContext_EmitTo_OutputFilesTruncate(t *testing.T) {
+	dir := writeFile(t, "2xx", "stale")
+	dir = filepath.Dir(dir)
+	result := run.Command(command.Awk(StatusSplitProgram{}, command.OutputFiles(dir))).
+		WithStdinLines("/ 200").Run()
+
+	assertion.NoError(t, result.Err)
+	data, err := os.ReadFile(filepath.Join(dir, "2xx"))
+	assertion.NoError(t, err)
+	assertion.Equal(t, string(data), "/ 200\n", "files are recreated on every run")
+}
+
+// EscapeProgram writes outside of the OutputFiles directory
+type EscapeProgram struct {
+	command.SimpleProgram
+}
+
+func (p EscapeProgram) Begin(ctx *command.Context) error {
+	return ctx.EmitTo("../escaped", "x")
+}
+
+func TestContext_EmitTo_OutsideDirectory(t *testing.T) {
+	dir := t.TempDir()
+	result := run.Command(command.Awk(EscapeProgram{}, command.OutputFiles(filepath.Join(dir, "out")))).Run()
+
+	assertion.Error(t, result.Err)
+	assertion.True(t, errors.Is(result.Err, command.ErrUnknownOutput), "paths may not leave the directory")
+	_, err := os.Stat(filepath.Join(dir, "escaped"))
+	assertion.True(t, os.IsNotExist(err), "nothing is written")
+}
+
+func TestContext_EmitTo_OutsideRun(t *testing.T) {
+	ctx := &command.Context{}
+	assertion.Error(t, ctx.EmitTo("2xx", "x"))
+}
+
+func TestAwkE_InvalidOutputs(t *testing.T) {
+	_, err := command.AwkE(StatusSplitProgram{},
+		command.NamedOutput("", &bytes.Buffer{}),
+		command.NamedOutput("2xx", nil),
+		command.OutputFiles(""),
+	)
+
+	assertion.ErrorContains(t, err, `invalid NamedOutput "": needs a name and a writer`)
+	assertion.ErrorContains(t, err, `invalid NamedOutput "2xx"`)
+	assertion.ErrorContains(t, err, "invalid OutputFiles: needs a directory")
+}
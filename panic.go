@@ -0,0 +1,67 @@
+package command
+
+import (
+	"fmt"
+	"runtime/debug"
+)
+
+// NoRecover lets a panic in a method of the Program crash the program as
+// usual, with its stack trace, instead of failing the run with a PanicError
+type NoRecover bool
+
+func (n NoRecover) Configure(flags *flags) { flags.NoRecover = n }
+
+// PanicError is the error of a run whose Program panicked
+type PanicError struct {
+	// Method is the Program method that panicked: Begin, BeginPass,
+	// Condition, Action, ActionWriter or End
+	Method string
+
+	// NR and Name identify the record being processed (0 and "" outside of
+	// the record methods)
+	NR   int64
+	Name string
+
+	// Value is the value the Program panicked with
+	Value any
+
+	// Stack is the stack trace of the goroutine when it panicked
+	Stack []byte
+}
+
+func (p *PanicError) Error() string {
+	switch {
+	case p.NR == 0:
+		return fmt.Sprintf("awk: panic in %s: %v", p.Method, p.Value)
+	case p.Name != "":
+		return fmt.Sprintf("awk: panic in %s at record %d (%s): %v", p.Method, p.NR, p.Name, p.Value)
+	default:
+		return fmt.Sprintf("awk: panic in %s at record %d: %v", p.Method, p.NR, p.Value)
+	}
+}
+
+// Unwrap returns the value of the panic if it is an error
+func (p *PanicError) Unwrap() error {
+	err, _ := p.Value.(error)
+	return err
+}
+
+// catch turns a panic in the Program method being called into a PanicError
+// stored in err. It must be deferred; panics outside of the Program's
+// methods, or with NoRecover, go on.
+func (e *engine) catch(err *error) {
+	if e.noRecover || e.calling == "" {
+		return
+	}
+	v := recover()
+	if v == nil {
+		return
+	}
+	pe := &PanicError{Method: e.calling, Value: v, Stack: debug.Stack()}
+	switch e.calling {
+	case "Condition", "Action", "ActionWriter":
+		pe.NR, pe.Name = e.ctx.NR, e.name
+	}
+	e.calling = ""
+	*err = e.errorf("%w", pe)
+}
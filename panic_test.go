@@ -0,0 +1,84 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// PanickingProgram panics in Action on the record "boom", like a type
+// assertion on a missing variable, and in End if panicInEnd
+type PanickingProgram struct {
+	command.SimpleProgram
+	panicInEnd bool
+}
+
+func (p PanickingProgram) Action(ctx *command.Context) (string, bool) {
+	if ctx.Field(1) == "boom" {
+		_ = ctx.Var("missing").(int)
+	}
+	return ctx.Field(0), true
+}
+
+func (p PanickingProgram) End(ctx *command.Context) (string, error) {
+	if p.panicInEnd {
+		panic("end exploded")
+	}
+	return "", nil
+}
+
+func TestAwk_Panic_Action(t *testing.T) {
+	result := run.Command(command.Awk(PanickingProgram{})).
+		WithStdinLines("a", "b", "boom", "c").Run()
+
+	assertion.ErrorContains(t, result.Err, "awk: panic in Action at record 3: interface conversion")
+	assertion.Lines(t, result.Stdout, []string{"a", "b"})
+
+	var pe *command.PanicError
+	assertion.True(t, errors.As(result.Err, &pe), "a PanicError")
+	assertion.Equal(t, pe.Method, "Action", "method")
+	assertion.Equal(t, pe.NR, int64(3), "record")
+	assertion.True(t, bytes.Contains(pe.Stack, []byte("PanickingProgram.Action")), "the stack shows where it panicked")
+
+	var runtimeErr interface{ RuntimeError() }
+	assertion.True(t, errors.As(result.Err, &runtimeErr), "the runtime error is wrapped")
+}
+
+func TestAwk_Panic_FileName(t *testing.T) {
+	input := writeFile(t, "input.txt", "boom")
+	result := run.Command(command.Awk(PanickingProgram{}, input)).Run()
+
+	assertion.ErrorContains(t, result.Err, "awk: panic in Action at record 1 ("+input+"): ")
+}
+
+func TestAwk_Panic_End(t *testing.T) {
+	// The output before the panic is flushed from the write buffer
+	var stdout, stderr bytes.Buffer
+	cmd := command.Awk(PanickingProgram{panicInEnd: true}, command.WriteBufferSize(1<<16))
+	err := cmd.Executor()(context.Background(), strings.NewReader("a\nb\n"), &stdout, &stderr)
+
+	assertion.ErrorContains(t, err, "awk: panic in End: end exploded")
+	assertion.Equal(t, stdout.String(), "a\nb\n", "output before the panic is written")
+}
+
+func TestPipe_Panic(t *testing.T) {
+	result := run.Command(command.Pipe(command.SimpleProgram{}, PanickingProgram{})).
+		WithStdinLines("boom").Run()
+
+	assertion.ErrorContains(t, result.Err, "stage 2: awk: panic in Action at record 1: ")
+}
+
+func TestAwk_NoRecover(t *testing.T) {
+	defer func() {
+		v := recover()
+		assertion.True(t, v != nil, "the panic goes on")
+	}()
+	_ = run.Command(command.Awk(PanickingProgram{panicInEnd: true}, command.NoRecover(true))).Run()
+	t.Fatal("no panic")
+}
@@ -0,0 +1,151 @@
+package command
+
+import (
+	"bytes"
+	"cmp"
+	"io"
+)
+
+// Passthrough is implemented by Programs that emit every record unchanged.
+// When Passthrough returns true the engine copies the input to the output
+// instead of splitting and dispatching each record; Begin and End still run
+// and NR is still counted, but Condition and Action are not called.
+type Passthrough interface {
+	Passthrough() bool
+}
+
+// passthrough reports whether the records of p can be copied verbatim
+func passthrough(p Program) bool {
+	switch p := p.(type) {
+	case SimpleProgram, *SimpleProgram:
+		return true
+	case Passthrough:
+		return p.Passthrough()
+	default:
+		return false
+	}
+}
+
+// copyBufferSize is the chunk size of the pass-through fast path
+const copyBufferSize = 64 * 1024
+
+var (
+	crlf    = []byte("\r\n")
+	newline = []byte("\n")
+)
+
+// copyRecords writes r to w exactly as the record loop would for a
+// pass-through Program: "\r\n" terminators become "\n" and a final record
+// without terminator gets one, unless terminators are preserved or kept. It
+// counts the records in NR.
+func (e *engine) copyRecords(r io.Reader, w io.Writer) error {
+	if e.preserve || e.keep {
+		return e.copyVerbatim(r, w)
+	}
+	var (
+		buf       = make([]byte, cmp.Or(e.readBufferSize, copyBufferSize))
+		pendingCR bool // the previous chunk ended with '\r'
+		last      byte // last byte written
+		seen      bool // some input was read
+	)
+	write := func(p []byte) error {
+		if len(p) == 0 {
+			return nil
+		}
+		records := int64(bytes.Count(p, newline))
+		e.ctx.NR += records
+		e.ctx.FNR += records
+		e.stats.Records += records
+		e.stats.Emitted += records
+		e.stats.BytesWritten += int64(len(p))
+		last = p[len(p)-1]
+		_, err := w.Write(p)
+		return err
+	}
+
+	for {
+		if err := e.ctx.Context().Err(); err != nil {
+			return err
+		}
+		n, readErr := r.Read(buf)
+		chunk := buf[:n]
+		seen = seen || n > 0
+
+		if pendingCR && n > 0 {
+			pendingCR = false
+			if chunk[0] != '\n' {
+				if err := write([]byte{'\r'}); err != nil {
+					return err
+				}
+			}
+		}
+		for {
+			i := bytes.Index(chunk, crlf)
+			if i < 0 {
+				break
+			}
+			if err := write(chunk[:i]); err != nil {
+				return err
+			}
+			chunk = chunk[i+1:] // Drop the '\r', keep the '\n'
+		}
+		if len(chunk) > 0 && chunk[len(chunk)-1] == '\r' {
+			pendingCR, chunk = true, chunk[:len(chunk)-1]
+		}
+		if err := write(chunk); err != nil {
+			return err
+		}
+
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+
+	// A final '\r' is dropped and an unterminated last record is terminated
+	if seen && (last != '\n' || pendingCR) {
+		return write(newline)
+	}
+	return nil
+}
+
+// copyVerbatim writes r to w unchanged and counts its records in NR
+func (e *engine) copyVerbatim(r io.Reader, w io.Writer) error {
+	var (
+		buf       = make([]byte, cmp.Or(e.readBufferSize, copyBufferSize))
+		last byte = '\n'
+	)
+	count := func(records int64) {
+		e.ctx.NR += records
+		e.ctx.FNR += records
+		e.stats.Records += records
+		e.stats.Emitted += records
+	}
+	for {
+		if err := e.ctx.Context().Err(); err != nil {
+			return err
+		}
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			count(int64(bytes.Count(buf[:n], newline)))
+			e.stats.BytesWritten += int64(n)
+			last = buf[n-1]
+			if _, err := w.Write(buf[:n]); err != nil {
+				return err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	// An unterminated last record is a record too
+	if last != '\n' {
+		count(1)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	command "github.com/yupsh/awk"
+)
+
+// SlowPassthroughProgram behaves like SimpleProgram but is not recognized as a
+// pass-through, so it always takes the record loop
+type SlowPassthroughProgram struct {
+	command.SimpleProgram
+}
+
+// MarkedPassthroughProgram declares itself a pass-through and reports NR in End
+type MarkedPassthroughProgram struct {
+	command.SimpleProgram
+}
+
+func (p MarkedPassthroughProgram) Passthrough() bool { return true }
+
+func (p MarkedPassthroughProgram) End(ctx *command.Context) (string, error) {
+	return fmt.Sprintf("NR=%d", ctx.NR), nil
+}
+
+func TestAwk_Passthrough_MatchesRecordLoop(t *testing.T) {
+	// 65535 bytes of short lines, so the next byte is the last of the first 64KB chunk
+	boundary := strings.Repeat(strings.Repeat("x", 99)+"\n", 655) + strings.Repeat("y", 35)
+	inputs := map[string]string{
+		"empty":                "",
+		"trailing newline":     "a\nb\n",
+		"no trailing newline":  "a\nb",
+		"only newlines":        "\n\n",
+		"crlf":                 "a\r\nb\r\n",
+		"crlf without final":   "a\r\nb",
+		"final carriage":       "a\nb\r",
+		"lone carriage":        "\r",
+		"carriage mid-line":    "a\rb\n",
+		"crlf across chunks":   boundary + "\r\nnext\n",
+		"carriage at boundary": boundary + "\rnext",
+	}
+
+	for name, input := range inputs {
+		t.Run(name, func(t *testing.T) {
+			fast := execute(t, command.SimpleProgram{}, input)
+			slow := execute(t, SlowPassthroughProgram{}, input)
+			assertion.True(t, fast == slow, "fast path output is byte-identical to the record loop")
+		})
+	}
+}
+
+func TestAwk_Passthrough_Pointer(t *testing.T) {
+	assertion.Equal(t, execute(t, &command.SimpleProgram{}, "a\r\nb"), "a\nb\n", "pointer SimpleProgram")
+}
+
+func TestAwk_Passthrough_Marker(t *testing.T) {
+	output := execute(t, MarkedPassthroughProgram{}, "a\nb\nc", command.StartNR(10))
+	assertion.Equal(t, output, "a\nb\nc\nNR=13\n", "records copied and counted")
+}
+
+func TestAwk_Passthrough_CustomRS(t *testing.T) {
+	// A custom RS takes the record loop, which terminates records with newlines
+	output := execute(t, command.SimpleProgram{}, "a;b", command.RecordSeparator(";"))
+	assertion.Equal(t, output, "a\nb\n", "records split on RS")
+}
+
+// passthroughInput returns about size bytes of log-like lines
+func passthroughInput(size int) []byte {
+	var buf bytes.Buffer
+	for i := 0; buf.Len() < size; i++ {
+		fmt.Fprintf(&buf, "%d 127.0.0.1 GET /index.html 200 %d\n", i, i*7)
+	}
+	return buf.Bytes()
+}
+
+func benchmarkPassthrough(b *testing.B, prog command.Program) {
+	input := passthroughInput(100 << 20)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for range b.N {
+		err := command.Awk(prog).Executor()(context.Background(), bytes.NewReader(input), io.Discard, io.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAwk_Passthrough(b *testing.B) {
+	benchmarkPassthrough(b, command.SimpleProgram{})
+}
+
+func BenchmarkAwk_RecordLoop(b *testing.B) {
+	benchmarkPassthrough(b, SlowPassthroughProgram{})
+}
+
+func BenchmarkIOCopy(b *testing.B) {
+	input := passthroughInput(100 << 20)
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for range b.N {
+		// Hide ReaderFrom/WriterTo so the copy goes through a buffer like the fast path
+		dst, src := struct{ io.Writer }{io.Discard}, struct{ io.Reader }{bytes.NewReader(input)}
+		if _, err := io.Copy(dst, src); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAwk_BufferSizes(b *testing.B) {
+	input := passthroughInput(100 << 20)
+	for _, size := range []int{512, 4 << 10, 64 << 10, 1 << 20} {
+		for name, prog := range map[string]command.Program{"copy": command.SimpleProgram{}, "records": SlowPassthroughProgram{}} {
+			b.Run(fmt.Sprintf("%s/%d", name, size), func(b *testing.B) {
+				b.SetBytes(int64(len(input)))
+				for range b.N {
+					err := command.Awk(prog, command.ReadBufferSize(size), command.WriteBufferSize(size)).
+						Executor()(context.Background(), bytes.NewReader(input), io.Discard, io.Discard)
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
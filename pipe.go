@@ -0,0 +1,108 @@
+package command
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	gloo "github.com/gloo-foo/framework"
+)
+
+type stage struct {
+	Program
+	parameters []any
+}
+
+// Stage attaches parameters (FieldSeparator, OutputFieldSeparator, Variable, ...)
+// to one Program of a Pipe. They are applied on top of the Pipe's own parameters.
+func Stage(program Program, parameters ...any) Program {
+	return stage{Program: program, parameters: parameters}
+}
+
+type pipe struct {
+	stages     [2]Program
+	parameters []any
+	inputs     gloo.Inputs[gloo.File, flags]
+
+	// err lists the invalid parameters; the Executor fails with it
+	err error
+}
+
+// Pipe runs two Programs in a single command: every record emitted by first
+// (from Action or End) becomes an input record of second, which writes to stdout.
+// Each stage has its own Context. The parameters select the input and configure
+// both stages; wrap a Program with Stage to configure it alone. Invalid
+// parameters make the Executor fail as with Awk, and the command may run
+// concurrently as Awk's may.
+func Pipe(first, second Program, parameters ...any) gloo.Command {
+	p := pipe{
+		stages:     [2]Program{first, second},
+		parameters: parameters,
+		inputs:     initialize(parameters...),
+		err:        validate(parameters, first, second),
+	}
+	return p
+}
+
+func (p pipe) Executor() gloo.CommandExecutor {
+	return func(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer) (err error) {
+		if p.err != nil {
+			unsink(p.inputs.Flags)
+			return p.err
+		}
+		stdout, flush := buffer(stdout, p.inputs.Flags.WriteBufferSize)
+		defer func() {
+			if flushErr := flush(); err == nil {
+				err = flushErr
+			}
+		}()
+		stdout, closeEncoder := encode(stdout, p.inputs.Flags)
+		defer func() {
+			if closeErr := closeEncoder(); err == nil {
+				err = closeErr
+			}
+		}()
+
+		emit, out, done := sink(ctx, p.inputs.Flags, stdout)
+		defer func() {
+			if doneErr := done(); err == nil {
+				err = doneErr
+			}
+		}()
+
+		second := p.engine(ctx, 1, emit, stderr)
+		first := p.engine(ctx, 0, second.input, stderr)
+		lines := &lineWriter{emit: second.input}
+		first.out, second.out = lines, out
+		first.downstream = second
+		defer func() { first.finish(err) }()
+		defer func() { second.finish(err) }()
+
+		for _, e := range []*engine{first, second} {
+			if err := e.begin(); err != nil {
+				return err
+			}
+		}
+		if err := first.scanInputs(p.inputs, stdin); err != nil {
+			return err
+		}
+		if err := lines.flush(); err != nil {
+			return err
+		}
+		for _, e := range []*engine{first, second} {
+			if err := e.end(); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// engine creates the engine for stage i, emitting its records to emit
+func (p pipe) engine(ctx context.Context, i int, emit func(string, string) error, stderr io.Writer) *engine {
+	program, f := p.stages[i], p.inputs.Flags
+	if s, ok := program.(stage); ok {
+		program, f = s.Program, configure(p.parameters, s.parameters)
+	}
+	return newEngine(ctx, program, f, emit, stderr, fmt.Sprintf("stage %d", i+1))
+}
@@ -0,0 +1,142 @@
+package command_test
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// NormalizeProgram lowercases the name in $1 and re-emits "name qty" with OFS
+type NormalizeProgram struct {
+	command.SimpleProgram
+}
+
+func (p NormalizeProgram) Action(ctx *command.Context) (string, bool) {
+	name := strings.ToLower(strings.TrimSpace(ctx.Field(1)))
+	qty := strings.TrimSpace(ctx.Field(2))
+	if name == "" {
+		return "", false
+	}
+	return ctx.Print(name, qty), true
+}
+
+// TotalsProgram sums $2 per $1 and prints the totals in input order
+type TotalsProgram struct {
+	command.SimpleProgram
+	order  []string
+	totals map[string]int
+}
+
+func (p *TotalsProgram) Begin(ctx *command.Context) error {
+	p.order, p.totals = nil, map[string]int{}
+	return nil
+}
+
+func (p *TotalsProgram) Action(ctx *command.Context) (string, bool) {
+	qty, _ := strconv.Atoi(ctx.Field(2))
+	if _, seen := p.totals[ctx.Field(1)]; !seen {
+		p.order = append(p.order, ctx.Field(1))
+	}
+	p.totals[ctx.Field(1)] += qty
+	return "", false
+}
+
+func (p *TotalsProgram) End(ctx *command.Context) (string, error) {
+	lines := make([]string, len(p.order))
+	for i, name := range p.order {
+		lines[i] = fmt.Sprintf("%s=%d", name, p.totals[name])
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+func TestPipe_NormalizeThenSum(t *testing.T) {
+	result := run.Command(
+		command.Pipe(
+			command.Stage(NormalizeProgram{}, command.FieldSeparator(","), command.OutputFieldSeparator("\t")),
+			command.Stage(&TotalsProgram{}, command.FieldSeparator("\t")),
+		),
+	).WithStdinLines(
+		"Apple, 3",
+		"banana,2",
+		"",
+		" APPLE ,4",
+	).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"apple=7",
+		"banana=2",
+	})
+}
+
+// RecordNumberProgram emits NR and $0
+type RecordNumberProgram struct {
+	command.SimpleProgram
+}
+
+func (p RecordNumberProgram) Action(ctx *command.Context) (string, bool) {
+	return fmt.Sprintf("%d:%s", ctx.NR, ctx.Field(0)), true
+}
+
+func (p RecordNumberProgram) End(ctx *command.Context) (string, error) {
+	return "end", nil
+}
+
+func TestPipe_EachStageHasItsOwnContext(t *testing.T) {
+	result := run.Command(command.Pipe(RecordNumberProgram{}, RecordNumberProgram{}, command.StartNR(10))).
+		WithStdinLines("a", "b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"11:11:a",
+		"12:12:b",
+		"13:end",
+		"end",
+	})
+}
+
+func TestPipe_StageErrors(t *testing.T) {
+	result := run.Command(command.Pipe(command.SimpleProgram{}, ErrorInEndProgram{})).
+		WithStdinLines("line").Run()
+	assertion.ErrorContains(t, result.Err, "stage 2: END: end error")
+
+	result = run.Command(command.Pipe(ErrorInBeginProgram{}, command.SimpleProgram{})).
+		WithStdinLines("line").Run()
+	assertion.ErrorContains(t, result.Err, "stage 1: BEGIN: begin error")
+}
+
+func TestPipe_InputError(t *testing.T) {
+	result := run.Command(command.Pipe(command.SimpleProgram{}, command.SimpleProgram{})).
+		WithStdinError(errors.New("read failed")).Run()
+
+	assertion.ErrorContains(t, result.Err, "read failed")
+}
+
+func TestAwk_StageParameters(t *testing.T) {
+	// A Stage passed directly to Awk still carries its parameters
+	result := run.Command(command.Awk(command.Stage(FieldExtractorProgram{fieldIndex: 2}, command.FieldSeparator(":")))).
+		WithStdinLines("a:b:c").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"b"})
+}
+
+func TestAwk_StageParameters_CallerSlice(t *testing.T) {
+	parameters := make([]any, 1, 2)
+	parameters[0] = command.OutputFieldSeparator("-")
+	shared := parameters[:2]
+	shared[1] = command.FieldSeparator(",")
+
+	cmd := command.Awk(command.Stage(SwapProgram{}, command.FieldSeparator(":")), parameters...)
+	assertion.Equal(t, shared[1], any(command.FieldSeparator(",")), "the caller's backing array is left alone")
+
+	result := run.Command(cmd).WithStdinLines("a:b").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"b-a-0.125"})
+}
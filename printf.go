@@ -0,0 +1,187 @@
+package command
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/yupsh/awk/internal/text"
+)
+
+// Sprintf formats args like awk's sprintf, which differs from fmt.Sprintf:
+// arguments are converted to what the verb needs as awk does ("3.7abc" is 3.7
+// for %f, a number is its decimal text for %s), %c prints a number as the
+// character with that code and a string as its first character, %i and %u
+// are %d, negative numbers print as unsigned for %o, %x and %X, * takes a
+// width or precision from the arguments, and missing arguments are empty or
+// zero. Numbers for %s are formatted like Print.
+func (c *Context) Sprintf(format string, args ...any) string {
+	var sb strings.Builder
+	next := func() any {
+		if len(args) == 0 {
+			return nil
+		}
+		arg := args[0]
+		args = args[1:]
+		return arg
+	}
+	for {
+		i := strings.IndexByte(format, '%')
+		if i < 0 {
+			sb.WriteString(format)
+			return sb.String()
+		}
+		sb.WriteString(format[:i])
+		format = format[i:]
+
+		// The spec is %, flags, width and precision, each of them possibly *
+		spec := []byte{'%'}
+		j := 1
+		for j < len(format) && strings.IndexByte("-+ #0", format[j]) >= 0 {
+			spec = append(spec, format[j])
+			j++
+		}
+		if j < len(format) && format[j] == '*' {
+			width := int(awkNumber(next()))
+			if width < 0 {
+				spec = append(spec, '-')
+				width = -width
+			}
+			spec = strconv.AppendInt(spec, int64(width), 10)
+			j++
+		} else {
+			for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+				spec = append(spec, format[j])
+				j++
+			}
+		}
+		precision := false
+		if j < len(format) && format[j] == '.' {
+			precision = true
+			spec = append(spec, '.')
+			j++
+			if j < len(format) && format[j] == '*' {
+				spec = strconv.AppendInt(spec, max(int64(awkNumber(next())), 0), 10)
+				j++
+			} else {
+				for j < len(format) && format[j] >= '0' && format[j] <= '9' {
+					spec = append(spec, format[j])
+					j++
+				}
+			}
+		}
+		if j >= len(format) {
+			// A trailing incomplete spec is printed as it is
+			sb.WriteString(format)
+			return sb.String()
+		}
+
+		verb := format[j]
+		format = format[j+1:]
+		switch verb {
+		case '%':
+			sb.WriteByte('%')
+		case 'd', 'i':
+			fmt.Fprintf(&sb, string(spec)+"d", awkInt(next()))
+		case 'u':
+			fmt.Fprintf(&sb, string(spec)+"d", awkUint(next()))
+		case 'o', 'x', 'X':
+			fmt.Fprintf(&sb, string(append(spec, verb)), awkUint(next()))
+		case 'e', 'E', 'f', 'F', 'g', 'G':
+			fmt.Fprintf(&sb, string(append(spec, verb)), awkNumber(next()))
+		case 'c':
+			if precision {
+				// awk ignores the precision of %c
+				spec = spec[:strings.IndexByte(string(spec), '.')]
+			}
+			fmt.Fprintf(&sb, string(spec)+"s", awkChar(next()))
+		case 's':
+			arg := next()
+			if arg == nil {
+				arg = ""
+			}
+			fmt.Fprintf(&sb, string(spec)+"s", c.format(arg))
+		default:
+			// Not a verb: printed as it is
+			sb.WriteString(string(spec))
+			sb.WriteByte(verb)
+		}
+	}
+}
+
+// Printf writes args formatted like Sprintf to w
+func (c *Context) Printf(w io.Writer, format string, args ...any) (int, error) {
+	return io.WriteString(w, c.Sprintf(format, args...))
+}
+
+// awkNumber converts v to a number as awk does: strings by their numeric
+// prefix, booleans as 1 and 0, and anything else not numeric as 0
+func awkNumber(v any) float64 {
+	switch n := v.(type) {
+	case nil:
+		return 0
+	case string:
+		prefix, _ := text.NumericPrefix(n)
+		f, _ := strconv.ParseFloat(prefix, 64)
+		return f
+	case bool:
+		if n {
+			return 1
+		}
+		return 0
+	}
+	switch rv := reflect.ValueOf(v); rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	default:
+		return awkNumber(fmt.Sprint(v))
+	}
+}
+
+// awkInt converts v to an integer, truncated toward zero and clamped to the
+// range of an int64
+func awkInt(v any) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int64:
+		return n
+	}
+	f := awkNumber(v)
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f >= math.MaxInt64:
+		return math.MaxInt64
+	case f <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(f)
+}
+
+// awkUint converts v to an unsigned integer; negative numbers wrap around as
+// in C
+func awkUint(v any) uint64 {
+	return uint64(awkInt(v))
+}
+
+// awkChar returns the character %c prints for v: the first character of a
+// string, or the character whose code a number is
+func awkChar(v any) string {
+	switch s := v.(type) {
+	case nil:
+		return ""
+	case string:
+		_, size := utf8.DecodeRuneInString(s)
+		return s[:size]
+	}
+	return string(rune(awkInt(v)))
+}
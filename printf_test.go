@@ -0,0 +1,61 @@
+package command_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	command "github.com/yupsh/awk"
+)
+
+func TestContext_Sprintf(t *testing.T) {
+	ctx := &command.Context{}
+
+	tests := []struct {
+		name   string
+		format string
+		args   []any
+		want   string
+	}{
+		{"%c number", "%c", []any{65}, "A"},
+		{"%c float", "%c", []any{97.9}, "a"},
+		{"%c string", "%c", []any{"hello"}, "h"},
+		{"%c multibyte string", "%c", []any{"éa"}, "é"},
+		{"%c empty string", "[%c]", []any{""}, "[]"},
+		{"%c width", "%3c|%-3c|", []any{"x", 66}, "  x|B  |"},
+		{"%f numeric prefix", "%5.2f", []any{"3.7abc"}, " 3.70"},
+		{"%d string", "%d", []any{"42 apples"}, "42"},
+		{"%d truncates", "%d %d", []any{3.9, -3.9}, "3 -3"},
+		{"%i", "%i", []any{7}, "7"},
+		{"%u", "%u", []any{"12"}, "12"},
+		{"%x negative", "%x", []any{-1}, "ffffffffffffffff"},
+		{"%o", "%o", []any{8}, "10"},
+		{"%e", "%.2e", []any{"1500"}, "1.50e+03"},
+		{"%s number", "%s %s", []any{3, 0.5}, "3 0.5"},
+		{"%s precision", "%.3s", []any{"abcdef"}, "abc"},
+		{"%%", "100%%", nil, "100%"},
+		{"* width", "%*d|", []any{5, 42}, "   42|"},
+		{"* negative width", "%*d|", []any{-5, 42}, "42   |"},
+		{"* string width", "%*s|", []any{"4x", "ab"}, "  ab|"},
+		{"* precision", "%.*f", []any{1, 2.25}, "2.2"},
+		{"missing arguments", "[%s] [%d] [%c]", nil, "[] [0] []"},
+		{"unknown verb", "%q %s", []any{"a"}, "%q a"},
+		{"trailing %", "50%", nil, "50%"},
+		{"no verbs", "plain", []any{1}, "plain"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion.Equal(t, ctx.Sprintf(tt.format, tt.args...), tt.want, "Sprintf")
+		})
+	}
+}
+
+func TestContext_Printf(t *testing.T) {
+	ctx := &command.Context{}
+	var buf bytes.Buffer
+	n, err := ctx.Printf(&buf, "%-4s|%c\n", "ab", 0x263A)
+	assertion.NoError(t, err)
+	assertion.Equal(t, buf.String(), "ab  |☺\n", "output")
+	assertion.Equal(t, n, buf.Len(), "bytes written")
+}
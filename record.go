@@ -0,0 +1,52 @@
+package command
+
+import "slices"
+
+// Record is a read-only snapshot of a record, safe to keep after the engine
+// has moved on to the next one
+type Record struct {
+	fields   []string // fields[0] is $0
+	nr, fnr  int64
+	filename string
+	rt       string
+}
+
+// Snapshot returns a copy of the current record
+func (c *Context) Snapshot() *Record {
+	return &Record{fields: slices.Clone(c.Fields), nr: c.NR, fnr: c.FNR, filename: c.Filename, rt: c.RT}
+}
+
+// Field returns the field at the given index like Context.Field
+func (r *Record) Field(index int) string {
+	index = fieldIndex(len(r.fields), index)
+	if index < 0 || index >= len(r.fields) {
+		return ""
+	}
+	return r.fields[index]
+}
+
+// Text returns the whole record ($0)
+func (r *Record) Text() string { return r.Field(0) }
+
+// FieldsSlice returns a copy of fields 1..NF
+func (r *Record) FieldsSlice() []string {
+	if len(r.fields) <= 1 {
+		return []string{}
+	}
+	return slices.Clone(r.fields[1:])
+}
+
+// NF returns the number of fields of the record
+func (r *Record) NF() int { return max(len(r.fields)-1, 0) }
+
+// NR returns the number of the record
+func (r *Record) NR() int64 { return r.nr }
+
+// FNR returns the number of the record in its input
+func (r *Record) FNR() int64 { return r.fnr }
+
+// Filename returns the name of the input of the record, like Context.Filename
+func (r *Record) Filename() string { return r.filename }
+
+// RT returns the text that terminated the record
+func (r *Record) RT() string { return r.rt }
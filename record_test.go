@@ -0,0 +1,59 @@
+package command_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// ContextBeforeErrorProgram prints the record before every ERROR line with it
+type ContextBeforeErrorProgram struct {
+	command.SimpleProgram
+	previous **command.Record
+}
+
+func (p ContextBeforeErrorProgram) Action(ctx *command.Context) (string, bool) {
+	defer func() { *p.previous = ctx.Snapshot() }()
+	if !strings.HasPrefix(ctx.Field(1), "ERROR") || *p.previous == nil {
+		return "", false
+	}
+	prev := *p.previous
+	return ctx.Print(prev.NR(), prev.Text(), "->", ctx.NR, ctx.Field(0)), true
+}
+
+func TestContext_Snapshot(t *testing.T) {
+	var previous *command.Record
+	result := run.Command(command.Awk(ContextBeforeErrorProgram{previous: &previous})).
+		WithStdinLines("start", "load config", "ERROR missing key", "retry", "ERROR again").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"2 load config -> 3 ERROR missing key",
+		"4 retry -> 5 ERROR again",
+	})
+}
+
+func TestContext_Snapshot_Independent(t *testing.T) {
+	ctx := &command.Context{Fields: []string{"a b c", "a", "b", "c"}, NR: 7, FNR: 2, Filename: "in.txt", NF: 3, RT: "\n"}
+	r := ctx.Snapshot()
+
+	ctx.SetField(2, "changed")
+	ctx.Fields = []string{"x", "x"}
+
+	assertion.Equal(t, r.Text(), "a b c", "$0")
+	assertion.Equal(t, r.Field(2), "b", "fields are copied")
+	assertion.Equal(t, r.Field(-1), "c", "negative indexes count from the end")
+	assertion.Equal(t, r.Field(9), "", "out of range")
+	assertion.Equal(t, r.NF(), 3, "NF")
+	assertion.Equal(t, r.NR(), int64(7), "NR")
+	assertion.Equal(t, r.FNR(), int64(2), "FNR")
+	assertion.Equal(t, r.Filename(), "in.txt", "Filename")
+	assertion.Equal(t, r.RT(), "\n", "RT")
+
+	fields := r.FieldsSlice()
+	fields[0] = "mutated"
+	assertion.Equal(t, r.Field(1), "a", "FieldsSlice returns a copy")
+}
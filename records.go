@@ -0,0 +1,106 @@
+package command
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode/utf8"
+)
+
+// recordSplitter splits input into records on RS and remembers the text that
+// terminated the most recent record (awk's RT).
+//
+// A newline RS also accepts "\r\n", which becomes part of the terminator.
+// A single-character RS is matched literally and a longer one is a regular
+// expression, as in gawk. An empty RS, awk's paragraph mode, is not supported.
+type recordSplitter struct {
+	sep byte
+	re  *regexp.Regexp
+
+	// rt is the terminator of the last record returned by split
+	rt string
+}
+
+// errParagraphMode rejects an empty RS
+var errParagraphMode = errors.New(`invalid record separator "": paragraph mode is not supported`)
+
+func newRecordSplitter(rs string) (*recordSplitter, error) {
+	if rs == "" {
+		return nil, errParagraphMode
+	}
+	if len(rs) == 1 {
+		return &recordSplitter{sep: rs[0]}, nil
+	}
+	re, err := regexp.Compile(rs)
+	if err != nil {
+		return nil, fmt.Errorf("invalid record separator %q: %w", rs, err)
+	}
+	return &recordSplitter{re: re}, nil
+}
+
+// split is a bufio.SplitFunc returning one record per token
+func (s *recordSplitter) split(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	start, end := s.find(data, atEOF)
+	if start < 0 {
+		if !atEOF {
+			return 0, nil, nil // Request more data
+		}
+		// Final record without a terminator
+		s.rt = ""
+		if s.sep == '\n' {
+			return len(data), s.dropCR(data, ""), nil
+		}
+		return len(data), data, nil
+	}
+
+	s.rt = string(data[start:end])
+	if s.sep == '\n' {
+		return end, s.dropCR(data[:start], s.rt), nil
+	}
+	return end, data[:start], nil
+}
+
+// find locates the next terminator in data, returning -1 when there is none
+// yet. A regular expression match touching the end of data is only accepted
+// at EOF, since more input could extend it.
+func (s *recordSplitter) find(data []byte, atEOF bool) (start, end int) {
+	if s.re == nil {
+		if i := bytes.IndexByte(data, s.sep); i >= 0 {
+			return i, i + 1
+		}
+		return -1, -1
+	}
+	for offset := 0; offset <= len(data); {
+		loc := s.re.FindIndex(data[offset:])
+		if loc == nil {
+			return -1, -1
+		}
+		start, end = offset+loc[0], offset+loc[1]
+		if start == end {
+			// An empty match cannot terminate a record: look again past it
+			_, size := utf8.DecodeRune(data[end:])
+			offset = end + max(size, 1)
+			continue
+		}
+		if end == len(data) && !atEOF {
+			return -1, -1
+		}
+		return start, end
+	}
+	return -1, -1
+}
+
+// dropCR removes a trailing carriage return from a newline-terminated record,
+// moving it into the terminator
+func (s *recordSplitter) dropCR(record []byte, rt string) []byte {
+	if n := len(record); n > 0 && record[n-1] == '\r' {
+		s.rt = "\r" + rt
+		return record[:n-1]
+	}
+	return record
+}
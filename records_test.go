@@ -0,0 +1,278 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// TerminatorProgram shows each record with its terminator
+type TerminatorProgram struct {
+	command.SimpleProgram
+}
+
+func (p TerminatorProgram) Action(ctx *command.Context) (string, bool) {
+	return fmt.Sprintf("%d [%s] RT=%q", ctx.NR, ctx.Field(0), ctx.RT), true
+}
+
+// ReassembleProgram concatenates every $0 with its RT and prints the result in End
+type ReassembleProgram struct {
+	command.SimpleProgram
+	out *strings.Builder
+}
+
+func (p ReassembleProgram) Action(ctx *command.Context) (string, bool) {
+	p.out.WriteString(ctx.Field(0) + ctx.RT)
+	return "", false
+}
+
+// reassemble runs input through ReassembleProgram and returns the reconstructed text
+func reassemble(t *testing.T, input string, parameters ...any) string {
+	t.Helper()
+	var out strings.Builder
+	execute(t, ReassembleProgram{out: &out}, input, parameters...)
+	return out.String()
+}
+
+// execute runs a Program over the exact input text and returns stdout
+func execute(t *testing.T, prog command.Program, input string, parameters ...any) string {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(prog, parameters...).
+		Executor()(context.Background(), strings.NewReader(input), &stdout, &stderr)
+	assertion.NoError(t, err)
+	return stdout.String()
+}
+
+func TestAwk_RecordSeparator_Literal(t *testing.T) {
+	output := execute(t, TerminatorProgram{}, "a;b;c", command.RecordSeparator(";"))
+
+	assertion.Equal(t, output, strings.Join([]string{
+		`1 [a] RT=";"`,
+		`2 [b] RT=";"`,
+		`3 [c] RT=""`,
+	}, "\n")+"\n", "records")
+}
+
+func TestAwk_RecordSeparator_Regex(t *testing.T) {
+	output := execute(t, TerminatorProgram{}, "a,b;;c\n\nd", command.RecordSeparator("[,;]+|\n+"))
+
+	assertion.Equal(t, output, strings.Join([]string{
+		`1 [a] RT=","`,
+		`2 [b] RT=";;"`,
+		`3 [c] RT="\n\n"`,
+		`4 [d] RT=""`,
+	}, "\n")+"\n", "records")
+}
+
+func TestAwk_RecordSeparator_RegexEmptyMatches(t *testing.T) {
+	// ";*" matches the empty string before every character, which never ends a record
+	output := execute(t, TerminatorProgram{}, "ab;;é;c", command.RecordSeparator(";*"))
+
+	assertion.Equal(t, output, strings.Join([]string{
+		`1 [ab] RT=";;"`,
+		`2 [é] RT=";"`,
+		`3 [c] RT=""`,
+	}, "\n")+"\n", "records")
+}
+
+// ParagraphProgram sets RS to "" in Begin, asking for awk's paragraph mode
+type ParagraphProgram struct {
+	command.SimpleProgram
+}
+
+func (p ParagraphProgram) Begin(ctx *command.Context) error {
+	ctx.RS = ""
+	return nil
+}
+
+func TestAwk_RecordSeparator_Empty(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.RecordSeparator(""))
+	assertion.ErrorContains(t, err, `invalid record separator "": paragraph mode is not supported`)
+
+	_, err = command.AwkE(command.SimpleProgram{}, command.Variable{Name: "RS", Value: ""})
+	assertion.ErrorContains(t, err, "paragraph mode is not supported")
+
+	result := run.Command(command.Awk(ParagraphProgram{})).WithStdinLines("a", "", "b").Run()
+	assertion.ErrorContains(t, result.Err, "paragraph mode is not supported")
+	assertion.Empty(t, result.Stdout)
+}
+
+func BenchmarkAwk_RecordSeparator_Regex(b *testing.B) {
+	input := strings.Repeat("abc;;", 400_000)
+	for _, size := range []int{4 << 10, 64 << 10} {
+		b.Run(fmt.Sprint(size), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for range b.N {
+				err := command.Awk(command.SimpleProgram{}, command.RecordSeparator(";+"), command.ReadBufferSize(size)).
+					Executor()(context.Background(), strings.NewReader(input), io.Discard, io.Discard)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestAwk_RecordSeparator_FieldsStillSplit(t *testing.T) {
+	result := run.Command(command.Awk(FieldCountProgram{}, command.RecordSeparator(";"))).
+		WithStdinLines("a b;c d e;").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"2 fields",
+		"3 fields",
+		"0 fields", // the trailing newline is a record of its own
+	})
+}
+
+func TestAwk_RecordSeparator_InvalidRegex(t *testing.T) {
+	result := run.Command(command.Awk(command.SimpleProgram{}, command.RecordSeparator("(["))).
+		WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "invalid record separator")
+}
+
+func TestAwk_RT_Newlines(t *testing.T) {
+	output := execute(t, TerminatorProgram{}, "unix\nwindows\r\nlast")
+
+	assertion.Equal(t, output, strings.Join([]string{
+		`1 [unix] RT="\n"`,
+		`2 [windows] RT="\r\n"`,
+		`3 [last] RT=""`,
+	}, "\n")+"\n", "terminators")
+}
+
+func TestAwk_RT_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		parameters []any
+	}{
+		{"newlines", "a\nb\n", nil},
+		{"mixed line endings", "a\r\nb\n\r\nc", nil},
+		{"no final newline", "a\nb", nil},
+		{"empty records", "\n\n\n", nil},
+		{"literal RS", "a;b;;c;", []any{command.RecordSeparator(";")}},
+		{"regex RS", "a--b---c-", []any{command.RecordSeparator("-+")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertion.Equal(t, reassemble(t, tt.input, tt.parameters...), tt.input, "reassembled input")
+		})
+	}
+}
+
+// DropProgram drops the records containing "drop"
+type DropProgram struct {
+	command.SimpleProgram
+}
+
+func (p DropProgram) Condition(ctx *command.Context) bool {
+	return !strings.Contains(ctx.Field(0), "drop")
+}
+
+func TestAwk_PreserveTerminators(t *testing.T) {
+	inputs := []string{
+		"a\nb\r\nc",
+		"a\r\nb\r\n",
+		"a\n\r\n\nlast\r",
+		"",
+		"only",
+	}
+	for _, input := range inputs {
+		for _, prog := range []command.Program{command.SimpleProgram{}, SlowPassthroughProgram{}} {
+			output := execute(t, prog, input, command.PreserveTerminators())
+			assertion.Equal(t, output, input, fmt.Sprintf("%T round-trips %q", prog, input))
+		}
+	}
+}
+
+func TestAwk_PreserveTerminators_Filter(t *testing.T) {
+	input := "keep 1\r\ndrop 2\nkeep 3\ndrop 4\r\nkeep 5"
+	output := execute(t, DropProgram{}, input, command.PreserveTerminators())
+	assertion.Equal(t, output, "keep 1\r\nkeep 3\nkeep 5", "kept records are byte-identical")
+
+	output = execute(t, DropProgram{}, input)
+	assertion.Equal(t, output, "keep 1\nkeep 3\nkeep 5\n", "terminators are normalized by default")
+}
+
+func TestAwk_PreserveTerminators_CustomRS(t *testing.T) {
+	output := execute(t, SlowPassthroughProgram{}, "a;b;;c", command.RecordSeparator(";+"), command.PreserveTerminators())
+	assertion.Equal(t, output, "a;b;;c", "regex terminators are reproduced")
+}
+
+func TestAwk_PreserveTerminators_End(t *testing.T) {
+	output := execute(t, MarkedPassthroughProgram{}, "a\r\nb", command.PreserveTerminators())
+	assertion.Equal(t, output, "a\r\nbNR=2\n", "records keep their terminators, End output ends with a newline")
+}
+
+func TestPipe_Terminators(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	cmd := command.Pipe(command.SimpleProgram{}, TerminatorProgram{}, command.PreserveTerminators())
+	err := cmd.Executor()(context.Background(), strings.NewReader("a\r\nb"), &stdout, &stderr)
+
+	assertion.NoError(t, err)
+	assertion.Equal(t, stdout.String(), "1 [a] RT=\"\\r\\n\"\r\n2 [b] RT=\"\"", "the second stage sees the first stage's terminators")
+}
+
+// FieldsAndRecordProgram shows $0 and the fields of every record
+type FieldsAndRecordProgram struct {
+	command.SimpleProgram
+}
+
+func (p FieldsAndRecordProgram) Action(ctx *command.Context) (string, bool) {
+	return fmt.Sprintf("%q NF=%d last=%q", ctx.Field(0), ctx.NF, ctx.LastField()), true
+}
+
+func TestAwk_KeepTerminator(t *testing.T) {
+	output := execute(t, FieldsAndRecordProgram{}, "a b\r\nc\nd", command.KeepTerminator())
+	assertion.Equal(t, output, strings.Join([]string{
+		`"a b\r\n" NF=2 last="b"`,
+		`"c\n" NF=1 last="c"`,
+		`"d" NF=1 last="d"`,
+	}, "\n")+"\n", "$0 includes the terminator, fields do not")
+}
+
+func TestAwk_KeepTerminator_Passthrough(t *testing.T) {
+	for _, input := range []string{"a\nb\r\nc", "a\n\n", ""} {
+		for _, prog := range []command.Program{command.SimpleProgram{}, SlowPassthroughProgram{}} {
+			output := execute(t, prog, input, command.KeepTerminator())
+			assertion.Equal(t, output, input, fmt.Sprintf("%T round-trips %q", prog, input))
+		}
+	}
+}
+
+// FirstFieldXProgram sets $1 to X and outputs the rebuilt $0
+type FirstFieldXProgram struct {
+	command.SimpleProgram
+}
+
+func (p FirstFieldXProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.SetField(1, "X")
+	return ctx.Field(0), true
+}
+
+func TestAwk_KeepTerminator_SetField(t *testing.T) {
+	output := execute(t, FirstFieldXProgram{}, "a b\nc d\n", command.KeepTerminator())
+	assertion.Equal(t, output, "X b\nX d\n", "a rebuilt record is terminated with ORS")
+
+	output = execute(t, FirstFieldXProgram{}, "a b\nc d", command.KeepTerminator())
+	assertion.Equal(t, output, "X b\nX d\n", "so is the unterminated last record once changed")
+}
+
+func TestAwk_KeepTerminator_CustomRS(t *testing.T) {
+	output := execute(t, UppercaseProgram{}, "a;b;;c", command.RecordSeparator(";+"), command.KeepTerminator())
+	assertion.Equal(t, output, "A;B;;C\n", "output ending with the terminator is written as it is, other output gets ORS")
+
+	output = execute(t, FieldCountProgram{}, "a;b c", command.RecordSeparator(";"), command.KeepTerminator())
+	assertion.Equal(t, output, "1 fields\n2 fields\n", "other output is terminated with ORS")
+}
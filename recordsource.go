@@ -0,0 +1,103 @@
+package command
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RecordSource feeds records to the command that were read (and possibly
+// split) elsewhere, such as CSV rows or database rows, instead of text it
+// reads and splits itself.
+type RecordSource interface {
+	// Next returns the fields ($1..$NF) and text ($0) of the next record, or
+	// io.EOF after the last one. Nil fields are split from raw with FS; an
+	// empty raw is the fields joined with OFS.
+	Next() (fields []string, raw string, err error)
+}
+
+type fromSource struct{ src RecordSource }
+
+// FromSource makes the command read its records from src instead of its file
+// operands or stdin. NR counts them as usual and RT is empty. A RecordSource
+// is read once, so it cannot feed a MultiPass Program.
+func FromSource(src RecordSource) fromSource { return fromSource{src} }
+
+func (s fromSource) Configure(flags *flags) { flags.RecordSource = s.src }
+
+// SliceSource is a RecordSource over records already split into fields
+type SliceSource struct {
+	records [][]string
+}
+
+// NewSliceSource returns a RecordSource yielding records in order
+func NewSliceSource(records [][]string) *SliceSource {
+	return &SliceSource{records: records}
+}
+
+func (s *SliceSource) Next() ([]string, string, error) {
+	if len(s.records) == 0 {
+		return nil, "", io.EOF
+	}
+	fields := s.records[0]
+	s.records = s.records[1:]
+	if fields == nil {
+		fields = []string{}
+	}
+	return fields, "", nil
+}
+
+// textSource is the RecordSource of text inputs: records separated by RS, or
+// tokenized by a RecordSplitter, and split into fields by the engine
+type textSource struct {
+	scanner  *bufio.Scanner
+	splitter *recordSplitter
+	limit    int
+}
+
+func (t *textSource) Next() ([]string, string, error) {
+	if t.scanner.Scan() {
+		return nil, t.scanner.Text(), nil
+	}
+	err := t.scanner.Err()
+	if errors.Is(err, bufio.ErrTooLong) {
+		return nil, "", fmt.Errorf("longer than %d bytes: %w", t.limit, err)
+	}
+	return nil, "", cmp.Or(err, io.EOF)
+}
+
+// RT returns the terminator of the last record
+func (t *textSource) RT() string {
+	return t.splitter.rt
+}
+
+type fromChannel struct{ ch <-chan string }
+
+// FromChannel makes the command read its records from ch instead of its file
+// operands or stdin: every string received is a record, split with FS, and
+// closing ch ends the input. Waiting for a record stops when the run is
+// cancelled. Like FromSource, it cannot feed a MultiPass Program.
+func FromChannel(ch <-chan string) fromChannel { return fromChannel{ch} }
+
+func (c fromChannel) Configure(flags *flags) { flags.RecordChannel = c.ch }
+
+// channelSource is the RecordSource of FromChannel
+type channelSource struct {
+	ctx context.Context
+	ch  <-chan string
+}
+
+func (c channelSource) Next() ([]string, string, error) {
+	select {
+	case <-c.ctx.Done():
+		return nil, "", c.ctx.Err()
+	case record, ok := <-c.ch:
+		if !ok {
+			return nil, "", io.EOF
+		}
+		return nil, record, nil
+	}
+}
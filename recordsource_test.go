@@ -0,0 +1,110 @@
+package command_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// CityProgram prints NR, NF, the second field and $0 of every record
+type CityProgram struct {
+	command.SimpleProgram
+}
+
+func (p CityProgram) Action(ctx *command.Context) (string, bool) {
+	return ctx.Print(ctx.NR, ctx.NF, "["+ctx.Field(2)+"]", ctx.Field(0)), true
+}
+
+func TestAwk_FromSource_Slice(t *testing.T) {
+	src := command.NewSliceSource([][]string{
+		{"1", "New York", "8.3"},
+		{"2", "Los Angeles"},
+		nil,
+	})
+	result := run.Command(command.Awk(CityProgram{}, command.FromSource(src),
+		command.OutputFieldSeparator("|"))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"1|3|[New York]|1|New York|8.3",
+		"2|2|[Los Angeles]|2|Los Angeles",
+		"3|0|[]|",
+	})
+}
+
+// rowSource is a RecordSource like a database cursor, failing after its rows
+type rowSource struct {
+	rows [][2]string
+	err  error
+}
+
+func (s *rowSource) Next() ([]string, string, error) {
+	if len(s.rows) == 0 {
+		return nil, "", s.err
+	}
+	row := s.rows[0]
+	s.rows = s.rows[1:]
+	if row[0] == "" {
+		return nil, row[1], nil
+	}
+	return []string{row[0]}, row[1], nil
+}
+
+func TestAwk_FromSource_Raw(t *testing.T) {
+	src := &rowSource{rows: [][2]string{{"a", "a,b"}, {"", "c d"}}, err: io.EOF}
+	result := run.Command(command.Awk(CityProgram{}, command.FromSource(src), command.StartNR(10))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"11 1 [] a,b", "12 2 [d] c d"})
+}
+
+func TestAwk_FromSource_Error(t *testing.T) {
+	failure := errors.New("connection lost")
+	src := &rowSource{rows: [][2]string{{"a", ""}}, err: failure}
+	prog := &CountingProgram{}
+	result := run.Command(command.Awk(prog, command.FromSource(src))).Run()
+
+	assertion.True(t, errors.Is(result.Err, failure), "the source's error is returned")
+	assertion.Equal(t, prog.count, 1, "records before the error are processed")
+}
+
+func TestAwk_FromSource_Tail(t *testing.T) {
+	src := command.NewSliceSource([][]string{{"a"}, {"b", "c"}, {"d"}})
+	result := run.Command(command.Awk(CityProgram{}, command.FromSource(src), command.TailRecords(2))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"2 2 [c] b c", "3 1 [] d"})
+}
+
+func TestAwk_FromSource_Pipe(t *testing.T) {
+	src := command.NewSliceSource([][]string{{"x", "y"}})
+	result := run.Command(command.Pipe(CityProgram{}, LineNumberProgram{}, command.FromSource(src))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: 1 2 [y] x y"})
+}
+
+func TestAwk_FromSource_MultiPass(t *testing.T) {
+	var total float64
+	var passes []string
+	src := command.NewSliceSource([][]string{{"a", "1"}})
+	result := run.Command(command.Awk(PercentProgram{total: &total, passes: &passes}, command.FromSource(src))).Run()
+
+	assertion.ErrorContains(t, result.Err, "a RecordSource can only be read once")
+}
+
+func TestAwkE_InvalidFromSource(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.FromSource(nil))
+	assertion.ErrorContains(t, err, "invalid FromSource: nil")
+
+	_, err = command.AwkE(command.SimpleProgram{}, "input.txt", command.FromSource(command.NewSliceSource(nil)))
+	assertion.ErrorContains(t, err, "FromSource cannot be combined with file operands or Sources")
+
+	cmd := command.Pipe(command.Stage(command.SimpleProgram{}, command.FromSource(command.NewSliceSource(nil))), command.SimpleProgram{})
+	result := run.Command(cmd).Run()
+	assertion.ErrorContains(t, result.Err, "stage 1: FromSource and FromChannel must be given to Pipe, not Stage")
+}
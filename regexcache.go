@@ -0,0 +1,81 @@
+package command
+
+import (
+	"cmp"
+	"container/list"
+	"regexp"
+)
+
+// RegexpCacheSize is the number of regular expressions ctx.Regexp keeps
+// compiled during a run (default 256), least recently used first out
+type RegexpCacheSize int
+
+func (n RegexpCacheSize) Configure(flags *flags) { flags.RegexpCacheSize = n }
+
+// defaultRegexpCacheSize is the default size of the cache of ctx.Regexp
+const defaultRegexpCacheSize = 256
+
+// regexpCache keeps the most recently used compiled regular expressions
+type regexpCache struct {
+	size    int
+	entries map[regexpKey]*list.Element
+	order   list.List // most recently used first
+}
+
+// regexpKey identifies a compiled pattern; longest ones prefer the
+// leftmost-longest match, like awk
+type regexpKey struct {
+	pattern string
+	longest bool
+}
+
+type regexpEntry struct {
+	key regexpKey
+	re  *regexp.Regexp
+}
+
+func newRegexpCache(size int) *regexpCache {
+	return &regexpCache{size: cmp.Or(size, defaultRegexpCacheSize), entries: make(map[regexpKey]*list.Element)}
+}
+
+// get returns the compiled pattern, compiling it unless it is cached
+func (r *regexpCache) get(key regexpKey) (*regexp.Regexp, error) {
+	if el, ok := r.entries[key]; ok {
+		r.order.MoveToFront(el)
+		return el.Value.(*regexpEntry).re, nil
+	}
+	re, err := regexp.Compile(key.pattern)
+	if err != nil {
+		return nil, err
+	}
+	if key.longest {
+		re.Longest()
+	}
+	if r.order.Len() >= r.size {
+		oldest := r.order.Back()
+		delete(r.entries, oldest.Value.(*regexpEntry).key)
+		r.order.Remove(oldest)
+	}
+	r.entries[key] = r.order.PushFront(&regexpEntry{key: key, re: re})
+	return re, nil
+}
+
+// Regexp returns the compiled regular expression pattern, for patterns built
+// while the run goes, like awk's $0 ~ prefix $1. The run keeps the most
+// recently used ones compiled (see RegexpCacheSize), so a pattern that comes
+// back is not compiled again. Prefix it with (?i) to ignore case.
+func (c *Context) Regexp(pattern string) (*regexp.Regexp, error) {
+	return c.compile(regexpKey{pattern: pattern})
+}
+
+// compile returns the compiled pattern of key from the run's cache
+func (c *Context) compile(key regexpKey) (*regexp.Regexp, error) {
+	if c.mu != nil {
+		c.mu.Lock()
+		defer c.mu.Unlock()
+	}
+	if c.regexps == nil {
+		c.regexps = newRegexpCache(c.regexpCacheSize)
+	}
+	return c.regexps.get(key)
+}
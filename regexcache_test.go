@@ -0,0 +1,98 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// PrefixMatchProgram prints the records whose $2 starts with $1, like
+// awk '$2 ~ "^" $1'
+type PrefixMatchProgram struct {
+	command.SimpleProgram
+	ignoreCase bool
+}
+
+func (p PrefixMatchProgram) Condition(ctx *command.Context) bool {
+	pattern := "^" + regexp.QuoteMeta(ctx.Field(1))
+	if p.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := ctx.Regexp(pattern)
+	if err != nil {
+		ctx.Abort(err)
+		return false
+	}
+	return re.MatchString(ctx.Field(2))
+}
+
+func TestContext_Regexp(t *testing.T) {
+	ctx := &command.Context{}
+	first, err := ctx.Regexp("a+")
+	assertion.NoError(t, err)
+	again, err := ctx.Regexp("a+")
+	assertion.NoError(t, err)
+	assertion.True(t, first == again, "a cached pattern is not compiled again")
+	folded, err := ctx.Regexp("(?i)a+")
+	assertion.NoError(t, err)
+	assertion.True(t, folded != first, "ignoring case is another pattern")
+	assertion.True(t, folded.MatchString("A") && !first.MatchString("A"), "each keeps its flags")
+
+	_, err = ctx.Regexp("[")
+	assertion.ErrorContains(t, err, "missing closing ]")
+}
+
+func TestAwk_Regexp(t *testing.T) {
+	input := []string{"ap apple", "ba Banana", "ap Apricot", "ch peach"}
+	result := run.Command(command.Awk(PrefixMatchProgram{})).WithStdinLines(input...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"ap apple"})
+
+	result = run.Command(command.Awk(PrefixMatchProgram{ignoreCase: true}, command.RegexpCacheSize(1))).
+		WithStdinLines(input...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"ap apple", "ba Banana", "ap Apricot"})
+}
+
+func TestAwkE_RegexpCacheSize(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.RegexpCacheSize(0))
+	assertion.ErrorContains(t, err, "invalid RegexpCacheSize 0: must be positive")
+}
+
+// recompileProgram is PrefixMatchProgram compiling the pattern for every record
+type recompileProgram struct {
+	command.SimpleProgram
+}
+
+func (p recompileProgram) Condition(ctx *command.Context) bool {
+	return regexp.MustCompile("^" + regexp.QuoteMeta(ctx.Field(1))).MatchString(ctx.Field(2))
+}
+
+func BenchmarkContext_Regexp(b *testing.B) {
+	var sb strings.Builder
+	for i := range 100_000 {
+		fmt.Fprintf(&sb, "k%d k%d-%d\n", i%16, i%15, i)
+	}
+	input := []byte(sb.String())
+	for name, prog := range map[string]command.Program{"cached": PrefixMatchProgram{}, "recompiled": recompileProgram{}} {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for range b.N {
+				err := command.Awk(prog).Executor()(context.Background(), bytes.NewReader(input), io.Discard, io.Discard)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
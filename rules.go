@@ -0,0 +1,113 @@
+package command
+
+import "fmt"
+
+// rules is the Program of Rules
+type rules []Program
+
+// Rules returns a Program running programs as the rules of a single awk
+// program, sharing one Context: Begin runs for each of them in order, then
+// every record goes through each rule whose Condition holds, until a rule
+// calls ctx.Next or ctx.Exit, and End runs for each of them in order. The
+// records the rules return from Action and End are output as they come,
+// terminated with ORS like EmitFields; an ActionWriter rule writes its
+// records itself. A rule aborting the run drops its own output, not the output
+// of the rules before it. A MultiPass rule fails the run: the rules share a
+// single pass over the input.
+func Rules(programs ...Program) Program {
+	return rules(programs)
+}
+
+func (r rules) Begin(ctx *Context) error {
+	for i, p := range r {
+		if _, ok := p.(MultiPass); ok {
+			return fmt.Errorf("rule %d: MultiPass Programs cannot be rules", i+1)
+		}
+	}
+	for _, p := range r {
+		if err := p.Begin(ctx); err != nil {
+			return err
+		}
+		if ctx.exited() != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (r rules) Condition(ctx *Context) bool { return true }
+
+func (r rules) Action(ctx *Context) (string, bool) {
+	ctx.next = false
+	for _, p := range r {
+		if !p.Condition(ctx) {
+			continue
+		}
+		if aw, ok := p.(ActionWriter); ok {
+			if err := writeRule(ctx, aw); err != nil {
+				ctx.Abort(err)
+			}
+			if ctx.aborted() != nil || ctx.next || ctx.exited() != nil {
+				break
+			}
+			continue
+		}
+		output, emit := p.Action(ctx)
+		if ctx.aborted() != nil {
+			break
+		}
+		if emit {
+			if err := ctx.emitRecord(output); err != nil {
+				ctx.Abort(err)
+				break
+			}
+		}
+		if ctx.next || ctx.exited() != nil {
+			break
+		}
+	}
+	return "", false
+}
+
+// writeRule runs the ActionWriter of a rule, outputting the records it writes
+func writeRule(ctx *Context, aw ActionWriter) error {
+	if ctx.output == nil {
+		return errNoOutput
+	}
+	lines := &lineWriter{emit: ctx.output}
+	if _, err := aw.ActionWriter(ctx, lines); err != nil {
+		return err
+	}
+	return lines.flush()
+}
+
+func (r rules) End(ctx *Context) (string, error) {
+	for _, p := range r {
+		output, err := p.End(ctx)
+		if err != nil {
+			return "", err
+		}
+		if output == "" {
+			continue
+		}
+		if err := ctx.emitRecord(output); err != nil {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Reset forwards to the rules that are Resetters
+func (r rules) Reset() {
+	for _, p := range r {
+		if rs, ok := p.(Resetter); ok {
+			rs.Reset()
+		}
+	}
+}
+
+// Next stops the current record from going through the remaining rules of a
+// Rules chain, like awk's next
+func (c *Context) Next() {
+	c.next = true
+}
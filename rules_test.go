@@ -0,0 +1,91 @@
+package command_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// skipComments drops comment records from the remaining rules, counting them
+type skipComments struct {
+	command.SimpleProgram
+}
+
+func (skipComments) Condition(ctx *command.Context) bool {
+	return strings.HasPrefix(ctx.Field(0), "#")
+}
+
+func (skipComments) Action(ctx *command.Context) (string, bool) {
+	ctx.AddVar("comments", 1)
+	ctx.Next()
+	return "", false
+}
+
+// footer outputs the number of comments the other rules counted
+type footer struct {
+	command.SimpleProgram
+}
+
+func (footer) Condition(ctx *command.Context) bool { return false }
+
+func (footer) End(ctx *command.Context) (string, error) {
+	return fmt.Sprint("comments: ", ctx.Var("comments")), nil
+}
+
+func TestRules(t *testing.T) {
+	result := run.Command(command.Awk(command.Rules(
+		skipComments{},
+		command.GroupBy(1, 2, command.Sum),
+		footer{},
+	))).WithStdinLines("# sales", "books 12.5", "games 30", "# games", "books 7.5").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"books 20", "games 30", "comments: 2"})
+}
+
+func TestRules_Order(t *testing.T) {
+	result := run.Command(command.Awk(command.Rules(
+		command.FilterFunc(func(ctx *command.Context) bool { return ctx.Field(1) != "b" }),
+		LineNumberProgram{},
+	))).WithStdinLines("a", "b", "c").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a", "1: a", "2: b", "c", "3: c"})
+}
+
+func TestRules_Abort(t *testing.T) {
+	result := run.Command(command.Awk(command.Rules(
+		LineNumberProgram{},
+		AbortProgram{},
+		LineNumberProgram{},
+	))).WithStdinLines("a", "bad", "c").Run()
+
+	assertion.ErrorContains(t, result.Err, errCorrupt.Error())
+	assertion.Lines(t, result.Stdout, []string{"1: a", "a", "1: a", "2: bad"})
+}
+
+func TestRules_ActionWriter(t *testing.T) {
+	result := run.Command(command.Awk(command.Rules(
+		ExplodeProgram{},
+		LineNumberProgram{},
+	))).WithStdinLines("2 a", "0 b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 1", "a 2", "1: 2 a", "2: 0 b", "done"})
+}
+
+func TestRules_MultiPass(t *testing.T) {
+	var total float64
+	var passes []string
+	result := run.Command(command.Awk(command.Rules(
+		LineNumberProgram{},
+		PercentProgram{total: &total, passes: &passes},
+	))).WithStdinLines("a 1").Run()
+
+	assertion.ErrorContains(t, result.Err, "rule 2: MultiPass Programs cannot be rules")
+	assertion.Empty(t, result.Stdout)
+}
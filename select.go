@@ -0,0 +1,72 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// selectFields is the Program of SelectFields and SelectColumns
+type selectFields struct {
+	SimpleProgram
+	indexes []int
+
+	// columns are the names of the header's fields to select, resolved to
+	// indexes on the first record of every run (nil for SelectFields)
+	columns []string
+}
+
+// resolved are the indexes of the columns of SelectColumns for a run, set
+// once the header is read
+type resolved struct{ indexes []int }
+
+// SelectFields returns a Program emitting the given fields of every record
+// joined with OFS, like awk '{ print $2, $1 }' or cut with reordering. Fields
+// may repeat, negative indexes count from the end as in ctx.Field, and fields
+// a record does not have are empty.
+func SelectFields(indexes ...int) Program {
+	return &selectFields{indexes: indexes}
+}
+
+// SelectColumns is SelectFields selecting fields by name: the first record is
+// a header naming the columns, emitted with the selected names too. A name
+// the header lacks fails the run.
+func SelectColumns(names ...string) Program {
+	return &selectFields{columns: names}
+}
+
+func (s *selectFields) Action(ctx *Context) (string, bool) {
+	indexes := s.indexes
+	if s.columns != nil {
+		r := ctx.state(s, func() any { return new(resolved) }).(*resolved)
+		if r.indexes == nil {
+			var err error
+			if r.indexes, err = s.resolve(ctx); err != nil {
+				ctx.Abort(err)
+				return "", false
+			}
+		}
+		indexes = r.indexes
+	}
+	fields := make([]string, len(indexes))
+	for i, index := range indexes {
+		fields[i] = ctx.Field(index)
+	}
+	return strings.Join(fields, ctx.OFS), true
+}
+
+// resolve finds the indexes of the columns in the header record
+func (s *selectFields) resolve(ctx *Context) ([]int, error) {
+	header := make(map[string]int, ctx.NF)
+	for i := ctx.NF; i >= 1; i-- {
+		header[ctx.Field(i)] = i
+	}
+	indexes := make([]int, len(s.columns))
+	for i, name := range s.columns {
+		index, ok := header[name]
+		if !ok {
+			return nil, fmt.Errorf("SelectColumns: no column %q in the header", name)
+		}
+		indexes[i] = index
+	}
+	return indexes, nil
+}
@@ -0,0 +1,48 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestSelectFields(t *testing.T) {
+	result := run.Command(command.Awk(command.SelectFields(2, 1, 2, -1, 9))).
+		WithStdinLines("a b c", "x", "").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"b a b c ", " x  x ", "    "})
+}
+
+func TestSelectFields_Separators(t *testing.T) {
+	result := run.Command(command.Awk(command.SelectFields(3, 1),
+		command.FieldSeparator(":"), command.OutputFieldSeparator("\t"))).
+		WithStdinLines("root:x:0:0", "daemon:x:1:1").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"0\troot", "1\tdaemon"})
+}
+
+func TestSelectColumns(t *testing.T) {
+	prog := command.SelectColumns("price", "name")
+	result := run.Command(command.Awk(prog, command.FieldSeparator(","), command.OutputFieldSeparator(","))).
+		WithStdinLines("id,name,price", "1,apple,0.5", "2,pear").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"price,name", "0.5,apple", ",pear"})
+
+	// Every run reads its own header
+	result = run.Command(command.Awk(prog)).WithStdinLines("name price", "fig 2").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"price name", "2 fig"})
+}
+
+func TestSelectColumns_Unknown(t *testing.T) {
+	result := run.Command(command.Awk(command.SelectColumns("name", "colour"))).
+		WithStdinLines("id name", "1 apple").Run()
+
+	assertion.ErrorContains(t, result.Err, `record 1: SelectColumns: no column "colour" in the header`)
+	assertion.Equal(t, len(result.Stdout), 0, "nothing is emitted")
+}
@@ -0,0 +1,68 @@
+package command
+
+import (
+	"context"
+	"io"
+)
+
+type emitFunc func(record string)
+
+// EmitFunc hands every record the command outputs (returned by Action or
+// End, or emitted with ctx.EmitFields) to fn, without its terminator, instead
+// of writing it to stdout. Records are handed over in order, one at a time.
+func EmitFunc(fn func(record string)) emitFunc { return fn }
+
+func (fn emitFunc) Configure(flags *flags) { flags.EmitFunc = fn }
+
+type emitChannel chan<- string
+
+// EmitChannel sends every record the command outputs to ch instead of writing
+// it to stdout, like EmitFunc. A send blocks until the record is received; a
+// cancelled run stops waiting and fails with the context's error. The command
+// closes ch when the run ends, however it ends, so it can only run once.
+func EmitChannel(ch chan<- string) emitChannel { return ch }
+
+func (ch emitChannel) Configure(flags *flags) { flags.EmitChannel = ch }
+
+// sink returns where the records of a run go: emit receives the records the
+// Program outputs and out is the writer of ActionWriter Programs. Unless
+// EmitFunc or EmitChannel is set, both write to stdout. done delivers a final
+// unterminated record written to out and closes the channel.
+func sink(ctx context.Context, f flags, stdout io.Writer) (emit func(record, terminator string) error, out io.Writer, done func() error) {
+	var deliver func(record string) error
+	switch {
+	case f.EmitFunc != nil:
+		deliver = func(record string) error {
+			f.EmitFunc(record)
+			return nil
+		}
+	case f.EmitChannel != nil:
+		deliver = func(record string) error {
+			select {
+			case f.EmitChannel <- record:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	default:
+		return writeLines(stdout), stdout, func() error { return nil }
+	}
+
+	emit = func(record, _ string) error { return deliver(record) }
+	lines := &lineWriter{emit: emit}
+	return emit, lines, func() error {
+		err := lines.flush()
+		if f.EmitChannel != nil {
+			close(f.EmitChannel)
+		}
+		return err
+	}
+}
+
+// unsink closes the channel of a run that fails before it starts
+func unsink(f flags) {
+	if f.EmitChannel != nil {
+		close(f.EmitChannel)
+	}
+}
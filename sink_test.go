@@ -0,0 +1,137 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	gloo "github.com/gloo-foo/framework"
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestAwk_EmitFunc(t *testing.T) {
+	var records []string
+	result := run.Command(command.Awk(RowsProgram{},
+		command.EmitFunc(func(record string) { records = append(records, record) }))).
+		WithStdinLines("a b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, len(result.Stdout), 0, "nothing is written to stdout")
+	assertion.Lines(t, records, []string{"field value", "1 1 a", "1 2 b", "end of a b", "records 1", "average 1.5"})
+}
+
+func TestAwk_EmitFunc_ActionWriter(t *testing.T) {
+	var records []string
+	result := run.Command(command.Awk(ExplodeProgram{},
+		command.EmitFunc(func(record string) { records = append(records, record) }))).
+		WithStdinLines("2 x").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, records, []string{"x 1", "x 2", "done"})
+}
+
+func TestAwk_EmitFunc_Unterminated(t *testing.T) {
+	var records []string
+	result := run.Command(command.Awk(PartialWriterProgram{},
+		command.EmitFunc(func(record string) { records = append(records, record) }))).
+		WithStdinLines("a", "b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, records, []string{"a;b;"})
+}
+
+func TestPipe_EmitFunc(t *testing.T) {
+	var records []string
+	result := run.Command(command.Pipe(UppercaseProgram{}, LineNumberProgram{},
+		command.EmitFunc(func(record string) { records = append(records, record) }))).
+		WithStdinLines("a", "b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, records, []string{"1: A", "2: B"})
+}
+
+func TestAwk_EmitChannel(t *testing.T) {
+	ch := make(chan string)
+	var stdout, stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		cmd := command.Awk(LineNumberProgram{}, command.EmitChannel(ch))
+		done <- cmd.Executor()(context.Background(), bytes.NewBufferString("a\nb\n"), &stdout, &stderr)
+	}()
+
+	var records []string
+	for record := range ch {
+		records = append(records, record)
+	}
+	assertion.NoError(t, <-done)
+	assertion.Lines(t, records, []string{"1: a", "2: b"})
+}
+
+func TestAwk_EmitChannel_Cancel(t *testing.T) {
+	ch := make(chan string)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	r, w := io.Pipe()
+	defer w.Close()
+	done := make(chan error, 1)
+	go func() {
+		cmd := command.Awk(command.SimpleProgram{}, command.EmitChannel(ch))
+		done <- cmd.Executor()(ctx, r, io.Discard, io.Discard)
+	}()
+
+	// Nobody receives the second record: the send blocks until cancelled
+	_, _ = io.WriteString(w, "first\nsecond\n")
+	assertion.Equal(t, <-ch, "first", "records are sent in order")
+	cancel()
+
+	select {
+	case err := <-done:
+		assertion.True(t, errors.Is(err, context.Canceled), "a blocked send stops when cancelled")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the run did not stop when cancelled")
+	}
+	_, open := <-ch
+	assertion.False(t, open, "the channel is closed when the run ends")
+}
+
+func TestAwkE_InvalidEmit(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{},
+		command.EmitFunc(nil),
+		command.EmitChannel(make(chan string)),
+		command.EmitFunc(func(string) {}),
+	)
+
+	assertion.ErrorContains(t, err, "invalid EmitFunc: nil")
+	assertion.ErrorContains(t, err, "EmitFunc cannot be combined with EmitChannel")
+}
+
+func TestAwk_EmitChannel_InvalidOption(t *testing.T) {
+	for name, cmd := range map[string]func(ch chan string) gloo.Command{
+		"Awk": func(ch chan string) gloo.Command {
+			return command.Awk(command.SimpleProgram{}, command.EmitChannel(ch), command.ReadBufferSize(-1))
+		},
+		"Pipe": func(ch chan string) gloo.Command {
+			return command.Pipe(command.SimpleProgram{}, command.SimpleProgram{}, command.EmitChannel(ch), command.ReadBufferSize(-1))
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ch := make(chan string)
+			err := cmd(ch).Executor()(context.Background(), strings.NewReader("a\n"), io.Discard, io.Discard)
+			assertion.ErrorContains(t, err, "invalid ReadBufferSize")
+
+			select {
+			case _, open := <-ch:
+				assertion.False(t, open, "the channel is closed when the command fails to start")
+			case <-time.After(5 * time.Second):
+				t.Fatal("the channel was not closed")
+			}
+		})
+	}
+}
@@ -0,0 +1,190 @@
+package command
+
+import (
+	"cmp"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/yupsh/awk/internal/text"
+)
+
+// Entry is a key and its value in an aggregation map
+type Entry[V any] struct {
+	Key   string
+	Value V
+}
+
+// SortedByKey returns the entries of m sorted by key: numerically when every
+// key is a number, lexically otherwise
+func SortedByKey[M ~map[string]V, V any](m M) []Entry[V] {
+	entries := entries(m)
+	slices.SortFunc(entries, keyOrder[V](numericKeys(entries)))
+	return entries
+}
+
+// SortedByValue returns the entries of m by increasing value, ties by key
+func SortedByValue[M ~map[string]V, V cmp.Ordered](m M) []Entry[V] {
+	entries := entries(m)
+	byKey := keyOrder[V](numericKeys(entries))
+	slices.SortFunc(entries, func(a, b Entry[V]) int {
+		return cmp.Or(cmp.Compare(a.Value, b.Value), byKey(a, b))
+	})
+	return entries
+}
+
+// SortedByValueDesc returns the entries of m by decreasing value, ties by key
+func SortedByValueDesc[M ~map[string]V, V cmp.Ordered](m M) []Entry[V] {
+	entries := entries(m)
+	byKey := keyOrder[V](numericKeys(entries))
+	slices.SortFunc(entries, func(a, b Entry[V]) int {
+		return cmp.Or(cmp.Compare(b.Value, a.Value), byKey(a, b))
+	})
+	return entries
+}
+
+// Top returns at most the first n entries; n <= 0 returns them all
+func Top[V any](entries []Entry[V], n int) []Entry[V] {
+	if n <= 0 || n >= len(entries) {
+		return entries
+	}
+	return entries[:n]
+}
+
+// SortOrder selects the order of EmitSorted
+type SortOrder int
+
+const (
+	ByKey SortOrder = iota
+	ByValue
+	ByValueDesc
+)
+
+// EmitSorted emits the entries of the map or Array held by the named variable
+// as "key OFS value" records, like EmitFields, in the given order. Values
+// compare as numbers when both are numeric, as strings otherwise. limit > 0
+// emits only the first limit entries, e.g. the top 10 by value.
+func (c *Context) EmitSorted(name string, order SortOrder, limit int) error {
+	entries, err := c.entries(name)
+	if err != nil {
+		return err
+	}
+
+	byKey := keyOrder[any](numericKeys(entries))
+	switch order {
+	case ByValue:
+		slices.SortFunc(entries, func(a, b Entry[any]) int {
+			return cmp.Or(compareValues(a.Value, b.Value), byKey(a, b))
+		})
+	case ByValueDesc:
+		slices.SortFunc(entries, func(a, b Entry[any]) int {
+			return cmp.Or(compareValues(b.Value, a.Value), byKey(a, b))
+		})
+	default:
+		slices.SortFunc(entries, byKey)
+	}
+
+	for _, e := range Top(entries, limit) {
+		if err := c.EmitFields(e.Key, e.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// entries returns the entries of the map or Array held by the named variable
+func (c *Context) entries(name string) ([]Entry[any], error) {
+	v := c.Var(name)
+	if a, ok := v.(*Array); ok {
+		return a.entries(), nil
+	}
+	m := reflect.ValueOf(v)
+	if m.Kind() != reflect.Map || m.Type().Key().Kind() != reflect.String {
+		return nil, fmt.Errorf("EmitSorted: variable %q is not a map with string keys or an Array", name)
+	}
+	entries := make([]Entry[any], 0, m.Len())
+	for it := m.MapRange(); it.Next(); {
+		entries = append(entries, Entry[any]{Key: it.Key().String(), Value: it.Value().Interface()})
+	}
+	return entries, nil
+}
+
+// entries returns the entries of m in no particular order
+func entries[M ~map[string]V, V any](m M) []Entry[V] {
+	entries := make([]Entry[V], 0, len(m))
+	for k, v := range m {
+		entries = append(entries, Entry[V]{Key: k, Value: v})
+	}
+	return entries
+}
+
+// numericKeys reports whether every key is a number
+func numericKeys[V any](entries []Entry[V]) bool {
+	for _, e := range entries {
+		if _, ok := number(e.Key); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// sortKeys sorts keys like SortedByKey
+func sortKeys(keys []string) {
+	entries := make([]Entry[struct{}], len(keys))
+	for i, k := range keys {
+		entries[i].Key = k
+	}
+	slices.SortFunc(entries, keyOrder[struct{}](numericKeys(entries)))
+	for i, e := range entries {
+		keys[i] = e.Key
+	}
+}
+
+// keyOrder compares entries by key, numerically or lexically
+func keyOrder[V any](numeric bool) func(a, b Entry[V]) int {
+	if numeric {
+		return func(a, b Entry[V]) int {
+			x, _ := number(a.Key)
+			y, _ := number(b.Key)
+			return cmp.Or(cmp.Compare(x, y), strings.Compare(a.Key, b.Key))
+		}
+	}
+	return func(a, b Entry[V]) int { return strings.Compare(a.Key, b.Key) }
+}
+
+// compareValues compares two values numerically when both are numbers and
+// as strings otherwise, like awk's comparisons
+func compareValues(a, b any) int {
+	x, okA := number(a)
+	y, okB := number(b)
+	if okA && okB {
+		return cmp.Compare(x, y)
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+// number returns v as a number if it is one, or a string awk reads as one
+// entirely: hexadecimal, inf and nan are not numbers
+func number(v any) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case float64:
+		return n, true
+	case float32:
+		return float64(n), true
+	case string:
+		prefix, whole := text.NumericPrefix(n)
+		if prefix == "" || !whole {
+			return 0, false
+		}
+		f, _ := strconv.ParseFloat(prefix, 64) // out of range is ±Inf
+		return f, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,103 @@
+package command_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestSortedByKey(t *testing.T) {
+	lexical := command.SortedByKey(map[string]int{"b": 1, "a": 2, "10": 3})
+	assertion.Equal(t, fmt.Sprint(lexical), "[{10 3} {a 2} {b 1}]", "lexical keys")
+
+	numeric := command.SortedByKey(map[string]string{"10": "x", "9": "y", "-1.5": "z"})
+	assertion.Equal(t, fmt.Sprint(numeric), "[{-1.5 z} {9 y} {10 x}]", "numeric keys sort numerically")
+
+	special := command.SortedByKey(map[string]int{"10": 1, "9": 2, "inf": 3, "nan": 4})
+	assertion.Equal(t, fmt.Sprint(special), "[{10 1} {9 2} {inf 3} {nan 4}]", "inf and nan are not numbers")
+}
+
+func TestSortedByValue(t *testing.T) {
+	hits := map[string]int{"10.0.0.1": 5, "10.0.0.2": 9, "10.0.0.3": 5, "10.0.0.4": 1}
+
+	assertion.Equal(t, fmt.Sprint(command.SortedByValue(hits)),
+		"[{10.0.0.4 1} {10.0.0.1 5} {10.0.0.3 5} {10.0.0.2 9}]", "increasing, ties by key")
+	assertion.Equal(t, fmt.Sprint(command.Top(command.SortedByValueDesc(hits), 2)),
+		"[{10.0.0.2 9} {10.0.0.1 5}]", "top 2")
+	assertion.Equal(t, len(command.Top(command.SortedByValueDesc(hits), 0)), 4, "no limit")
+}
+
+// HitsProgram counts hits per $1 and reports the top entries in End
+type HitsProgram struct {
+	command.SimpleProgram
+	order command.SortOrder
+	limit int
+}
+
+func (p HitsProgram) Begin(ctx *command.Context) error {
+	ctx.SetVar("hits", map[string]int{})
+	return nil
+}
+
+func (p HitsProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.Var("hits").(map[string]int)[ctx.Field(1)]++
+	return "", false
+}
+
+func (p HitsProgram) End(ctx *command.Context) (string, error) {
+	return "", ctx.EmitSorted("hits", p.order, p.limit)
+}
+
+func TestContext_EmitSorted(t *testing.T) {
+	input := []string{"b", "a", "c", "a", "b", "a", "9", "10"}
+
+	result := run.Command(command.Awk(HitsProgram{order: command.ByValueDesc, limit: 2})).
+		WithStdinLines(input...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 3", "b 2"})
+
+	result = run.Command(command.Awk(HitsProgram{order: command.ByKey}, command.OutputFieldSeparator("\t"))).
+		WithStdinLines(input...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"10\t1", "9\t1", "a\t3", "b\t2", "c\t1"})
+
+	result = run.Command(command.Awk(HitsProgram{order: command.ByValue})).
+		WithStdinLines("10", "9", "9").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"10 1", "9 2"})
+}
+
+// ArrayHitsProgram is HitsProgram counting in an Array
+type ArrayHitsProgram struct {
+	HitsProgram
+}
+
+func (p ArrayHitsProgram) Begin(ctx *command.Context) error { return nil }
+
+func (p ArrayHitsProgram) Action(ctx *command.Context) (string, bool) {
+	ctx.ArrayVar("hits").Incr(ctx.Field(1), 1)
+	return "", false
+}
+
+func TestContext_EmitSorted_Array(t *testing.T) {
+	input := []string{"b", "a", "10", "a", "9", "b", "a"}
+
+	result := run.Command(command.Awk(ArrayHitsProgram{HitsProgram{order: command.ByValueDesc, limit: 2}})).
+		WithStdinLines(input...).Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 3", "b 2"})
+
+	result = run.Command(command.Awk(ArrayHitsProgram{HitsProgram{order: command.ByKey}})).
+		WithStdinLines("10", "9", "10").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"9 1", "10 2"})
+}
+
+func TestContext_EmitSorted_NotAMap(t *testing.T) {
+	ctx := &command.Context{}
+	ctx.SetVar("n", 1)
+	assertion.ErrorContains(t, ctx.EmitSorted("n", command.ByKey, 0), `variable "n" is not a map`)
+}
@@ -0,0 +1,28 @@
+package command
+
+import "io"
+
+// Source is an input the command reads instead of stdin, such as an HTTP
+// response body or a buffer. Several Sources are read one after another like
+// file operands, and Name is reported as the input's name (e.g. in
+// Stats.Files). The command never closes a Source's Reader, and a Reader can
+// only be read once, so a command with Sources should only run once.
+type Source struct {
+	Name   string
+	Reader io.Reader
+}
+
+// Sources are several inputs read in order
+type Sources []Source
+
+// InputReaders returns unnamed Sources reading rs in order
+func InputReaders(rs ...io.Reader) Sources {
+	sources := make(Sources, len(rs))
+	for i, r := range rs {
+		sources[i] = Source{Reader: r}
+	}
+	return sources
+}
+
+func (s Source) Configure(flags *flags)  { flags.Sources = append(flags.Sources, s) }
+func (s Sources) Configure(flags *flags) { flags.Sources = append(flags.Sources, s...) }
@@ -0,0 +1,62 @@
+package command_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// closeTracker records whether it was closed
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestAwk_InputReaders(t *testing.T) {
+	tracked := &closeTracker{Reader: strings.NewReader("c\n")}
+	result := run.Command(command.Awk(LineNumberProgram{},
+		command.InputReaders(bytes.NewBufferString("a\nb\n"), tracked))).
+		WithStdinLines("ignored").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: a", "2: b", "3: c"})
+	assertion.False(t, tracked.closed, "readers handed to the command are not closed")
+}
+
+func TestAwk_Sources(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(&DistinctVarProgram{},
+		command.Source{Name: "defaults", Reader: strings.NewReader("x\ny\n")},
+		command.Source{Name: "overrides", Reader: strings.NewReader("y\nz\n")},
+		command.ResetPerFile(),
+		command.StatsRecipient(&stats))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"x", "y", "y", "z", "distinct=2 start=<nil>"})
+	assertion.Equal(t, fmt.Sprint(stats.Files), "[{defaults 2} {overrides 2}]", "sources are named")
+}
+
+func TestAwkE_Sources_WithFiles(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, "data.txt", command.InputReaders(strings.NewReader("a")))
+	assertion.ErrorContains(t, err, "Sources cannot be combined with file operands")
+}
+
+func TestPipe_Sources(t *testing.T) {
+	cmd := command.Pipe(ConditionalProgram{}, UppercaseProgram{},
+		command.InputReaders(strings.NewReader("include:a\nskip\n"), strings.NewReader("include:b\n")))
+	result := run.Command(cmd).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"INCLUDE:A", "INCLUDE:B"})
+}
@@ -0,0 +1,39 @@
+package command
+
+import (
+	"bufio"
+	"bytes"
+)
+
+// RecordSplitter tokenizes the input into records, for formats no separator
+// describes (length-prefixed frames, multi-line stanzas). Every token is a
+// record, split into fields with FS as usual; RT is always empty. Tokens are
+// limited by ReadBufferSize and MaxRecordSize like records split on RS.
+type RecordSplitter bufio.SplitFunc
+
+func (s RecordSplitter) Configure(flags *flags) { flags.RecordSplitter = s }
+
+// ScanContinuationLines is a RecordSplitter joining every line that starts
+// with a space or a tab to the line before it, as in mail headers or
+// indented stanzas. The record keeps the newlines between its lines.
+func ScanContinuationLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	for pos := 0; ; {
+		i := bytes.IndexByte(data[pos:], '\n')
+		if i < 0 {
+			if atEOF && len(data) > 0 {
+				return len(data), data, nil
+			}
+			return 0, nil, nil
+		}
+		end := pos + i
+		if end+1 == len(data) && !atEOF {
+			// The next line may be a continuation
+			return 0, nil, nil
+		}
+		if end+1 < len(data) && (data[end+1] == ' ' || data[end+1] == '\t') {
+			pos = end + 1
+			continue
+		}
+		return end + 1, data[:end], nil
+	}
+}
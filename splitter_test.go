@@ -0,0 +1,97 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	command "github.com/yupsh/awk"
+)
+
+func TestAwk_RecordSplitter_ContinuationLines(t *testing.T) {
+	input := "Subject: hello\n  world\nFrom: me\nTo: you\n\tand them\n"
+	output := execute(t, FieldCountProgram{}, input, command.RecordSplitter(command.ScanContinuationLines))
+
+	assertion.Equal(t, output, execute(t, FieldCountProgram{},
+		"Subject: hello\n  world\x00From: me\x00To: you\n\tand them\x00", command.RecordSeparator("\x00")),
+		"continuation lines belong to the record before them")
+}
+
+func TestAwk_RecordSplitter_Tokens(t *testing.T) {
+	input := "a\n b\nc\n\td\n e\nf"
+	output := execute(t, TerminatorProgram{}, input, command.RecordSplitter(command.ScanContinuationLines))
+
+	assertion.Equal(t, output, strings.Join([]string{
+		`1 [a` + "\n" + ` b] RT=""`,
+		`2 [c` + "\n\td\n" + ` e] RT=""`,
+		`3 [f] RT=""`,
+	}, "\n")+"\n", "records")
+}
+
+// scanFrames splits length-prefixed frames: a decimal length, ':' and the data
+func scanFrames(data []byte, atEOF bool) (int, []byte, error) {
+	colon := bytes.IndexByte(data, ':')
+	if colon < 0 {
+		if atEOF && len(data) > 0 {
+			return 0, nil, fmt.Errorf("truncated frame header %q", data)
+		}
+		return 0, nil, nil
+	}
+	n, err := strconv.Atoi(string(data[:colon]))
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(data) < colon+1+n {
+		if atEOF {
+			return 0, nil, fmt.Errorf("truncated frame of %d bytes", n)
+		}
+		return 0, nil, nil
+	}
+	return colon + 1 + n, data[colon+1 : colon+1+n], nil
+}
+
+func TestAwk_RecordSplitter_Frames(t *testing.T) {
+	output := execute(t, FieldCountProgram{}, "5:a b c11:one\ntwo;six", command.RecordSplitter(scanFrames))
+	assertion.Equal(t, output, execute(t, FieldCountProgram{}, "a b c\x00one\ntwo;six", command.RecordSeparator("\x00")),
+		"frames may contain newlines")
+
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(command.SimpleProgram{}, command.RecordSplitter(scanFrames)).
+		Executor()(context.Background(), strings.NewReader("3:abc9:x"), &stdout, &stderr)
+	assertion.ErrorContains(t, err, "truncated frame of 9 bytes")
+	assertion.Equal(t, stdout.String(), "abc\n", "records before the error are processed")
+}
+
+func TestAwk_RecordSplitter_MaxRecordSize(t *testing.T) {
+	input := "x\n" + strings.Repeat(" y\n", 100)
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(command.SimpleProgram{}, command.RecordSplitter(command.ScanContinuationLines),
+		command.ReadBufferSize(16), command.MaxRecordSize(64)).
+		Executor()(context.Background(), strings.NewReader(input), &stdout, &stderr)
+
+	assertion.ErrorContains(t, err, "record 1 is longer than 64 bytes")
+}
+
+func TestAwkE_RecordSplitter_Conflict(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{},
+		command.RecordSplitter(command.ScanContinuationLines), command.RecordSeparator(";"))
+	assertion.ErrorContains(t, err, "RecordSplitter cannot be combined with RecordSeparator")
+
+	_, err = command.AwkE(command.SimpleProgram{}, command.RecordSplitter(nil))
+	assertion.ErrorContains(t, err, "invalid RecordSplitter")
+}
+
+func ExampleScanContinuationLines() {
+	input := "Host: example.com\nAccept: text/html,\n  application/json\n"
+	cmd := command.Awk(LineNumberProgram{}, command.RecordSplitter(command.ScanContinuationLines))
+	_ = cmd.Executor()(context.Background(), strings.NewReader(input), os.Stdout, os.Stderr)
+	// Output:
+	// 1: Host: example.com
+	// 2: Accept: text/html,
+	//   application/json
+}
@@ -0,0 +1,96 @@
+package command
+
+import (
+	"io"
+	"slices"
+	"time"
+)
+
+// Stats are the counters of a run
+type Stats struct {
+	// Records is the number of records read
+	Records int64
+
+	// Skipped is the number of records rejected by Condition
+	Skipped int64
+
+	// Emitted is the number of records output, by Action and End
+	Emitted int64
+
+	// BytesRead is the number of input bytes read
+	BytesRead int64
+
+	// BytesWritten is the number of output bytes written, terminators included
+	BytesWritten int64
+
+	// Warnings is the number of values the ready-made Programs (GroupBy,
+	// ColumnStats) skipped because they could not use them
+	Warnings int64
+
+	// Column are the statistics computed by a ColumnStats Program (nil for
+	// other Programs)
+	Column *StatsResult
+
+	// Files lists the records read from each input, in order.
+	// Stdin is named "" unless it was given as "-".
+	Files []FileStats
+
+	// StoppedEarly reports whether the run stopped reading its input early:
+	// the Program called Exit before End, a later Pipe stage called Exit, or
+	// the run was cancelled
+	StoppedEarly bool
+}
+
+// FileStats are the counters of one input
+type FileStats struct {
+	Name    string
+	Records int64
+}
+
+// Stats returns a snapshot of the run's counters so far; in End they are final
+func (c *Context) Stats() Stats {
+	if c.stats == nil {
+		return Stats{}
+	}
+	stats := *c.stats
+	stats.Files = slices.Clone(stats.Files)
+	return stats
+}
+
+// Totals are the figures of a run so far, for End to report throughput
+type Totals struct {
+	Stats
+
+	// Started is when the run began
+	Started time.Time
+}
+
+// Elapsed returns the time since the run began
+func (t Totals) Elapsed() time.Duration {
+	return time.Since(t.Started)
+}
+
+// Totals returns the counters of the run so far and when it began; in End
+// they cover the whole input
+func (c *Context) Totals() Totals {
+	return Totals{Stats: c.Stats(), Started: c.started}
+}
+
+// warn counts a value a ready-made Program could not use
+func (c *Context) warn() {
+	if c.stats != nil {
+		c.stats.Warnings++
+	}
+}
+
+// countingReader counts the bytes read through it
+type countingReader struct {
+	r io.Reader
+	n *int64
+}
+
+func (c countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	*c.n += int64(n)
+	return n, err
+}
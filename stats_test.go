@@ -0,0 +1,169 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// StatsReportProgram prints the final statistics in End
+type StatsReportProgram struct {
+	ConditionalProgram
+}
+
+func (p StatsReportProgram) End(ctx *command.Context) (string, error) {
+	s := ctx.Stats()
+	return fmt.Sprintf("records=%d skipped=%d emitted=%d", s.Records, s.Skipped, s.Emitted), nil
+}
+
+func TestAwk_Stats(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(ConditionalProgram{}, command.StatsRecipient(&stats))).
+		WithStdinLines("include:a", "skip", "include:b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, stats.Records, int64(3), "records")
+	assertion.Equal(t, stats.Skipped, int64(1), "skipped")
+	assertion.Equal(t, stats.Emitted, int64(2), "emitted")
+	assertion.Equal(t, stats.BytesRead, int64(len("include:a\nskip\ninclude:b\n")), "bytes read")
+	assertion.Equal(t, stats.BytesWritten, int64(len("include:a\ninclude:b\n")), "bytes written")
+	assertion.Equal(t, fmt.Sprint(stats.Files), "[{ 3}]", "stdin is counted as one unnamed input")
+	assertion.False(t, stats.StoppedEarly, "the whole input was read")
+}
+
+func TestAwk_Stats_End(t *testing.T) {
+	result := run.Command(command.Awk(StatsReportProgram{})).
+		WithStdinLines("include:a", "skip", "include:b").Run()
+
+	assertion.NoError(t, result.Err)
+	// The line printed by End is not counted in the figures it reports
+	assertion.Lines(t, result.Stdout, []string{"include:a", "include:b", "records=3 skipped=1 emitted=2"})
+}
+
+func TestAwk_Stats_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "a", "b")
+	second := writeFile(t, "second.txt", "c")
+
+	var stats command.Stats
+	result := run.Command(command.Awk(LineNumberProgram{}, first, "-", second, command.StatsRecipient(&stats))).
+		WithStdinLines("x", "y", "z").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, stats.Records, int64(6), "records")
+	assertion.Equal(t, fmt.Sprint(stats.Files),
+		fmt.Sprintf("[{%s 2} {- 3} {%s 1}]", first, second), "records per file")
+}
+
+func TestAwk_Stats_Passthrough(t *testing.T) {
+	var stats command.Stats
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(command.SimpleProgram{}, command.StatsRecipient(&stats)).
+		Executor()(context.Background(), strings.NewReader("a\r\nb\nc"), &stdout, &stderr)
+
+	assertion.NoError(t, err)
+	assertion.Equal(t, stats.Records, int64(3), "records")
+	assertion.Equal(t, stats.Emitted, int64(3), "emitted")
+	assertion.Equal(t, stats.BytesRead, int64(6), "bytes read")
+	assertion.Equal(t, stats.BytesWritten, int64(stdout.Len()), "bytes written")
+}
+
+func TestAwk_Stats_Cancelled(t *testing.T) {
+	runCtx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var stats command.Stats
+	prog := &CancellingProgram{cancel: cancel, afterNR: 2}
+	var stdout, stderr bytes.Buffer
+	err := command.Awk(prog, command.StatsRecipient(&stats)).
+		Executor()(runCtx, strings.NewReader("a\nb\nc\nd\n"), &stdout, &stderr)
+
+	assertion.True(t, errors.Is(err, context.Canceled), "run should stop with context.Canceled")
+	assertion.Equal(t, stats.Records, int64(2), "statistics are delivered when the run stops early")
+	assertion.Equal(t, stats.Emitted, int64(2), "emitted")
+	assertion.True(t, stats.StoppedEarly, "cancelled")
+}
+
+func TestAwk_Stats_Pipe(t *testing.T) {
+	var first, second command.Stats
+	cmd := command.Pipe(
+		command.Stage(ConditionalProgram{}, command.StatsRecipient(&first)),
+		command.Stage(UppercaseProgram{}, command.StatsRecipient(&second)),
+	)
+	result := run.Command(cmd).WithStdinLines("include:a", "skip").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, first.Records, int64(2), "first stage reads the input")
+	assertion.Equal(t, first.Emitted, int64(1), "first stage emits one record")
+	assertion.Equal(t, second.Records, int64(1), "second stage reads what the first emits")
+	assertion.Equal(t, second.Emitted, int64(1), "second stage emits one record")
+}
+
+// ThroughputProgram reports the run's totals in End
+type ThroughputProgram struct {
+	command.SimpleProgram
+	totals *command.Totals
+}
+
+func (p ThroughputProgram) End(ctx *command.Context) (string, error) {
+	*p.totals = ctx.Totals()
+	t := *p.totals
+	files := make([]string, len(t.Files))
+	for i, f := range t.Files {
+		files[i] = fmt.Sprintf("%s=%d", f.Name, f.Records)
+	}
+	return fmt.Sprintf("%d bytes, %d records, %d emitted [%s]", t.BytesRead, t.Records, t.Emitted,
+		strings.Join(files, " ")), nil
+}
+
+func TestContext_Totals(t *testing.T) {
+	first := writeFile(t, "first.txt", "a", "b")
+	second := writeFile(t, "second.txt", "c")
+
+	before := time.Now()
+	var totals command.Totals
+	result := run.Command(command.Awk(ThroughputProgram{totals: &totals}, first, "-", second)).
+		WithStdinLines("x").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"a", "b", "x", "c",
+		fmt.Sprintf("8 bytes, 4 records, 4 emitted [%s=2 -=1 %s=1]", first, second),
+	})
+	assertion.True(t, !totals.Started.Before(before) && !totals.Started.After(time.Now()), "start time")
+	assertion.True(t, totals.Elapsed() >= 0, "elapsed time")
+	assertion.False(t, totals.StoppedEarly, "the whole input was read")
+}
+
+func TestContext_Totals_Exit(t *testing.T) {
+	var totals command.Totals
+	result := run.Command(command.Awk(command.Rules(StopProgram{at: 2}, ThroughputProgram{totals: &totals}))).
+		WithStdinLines(numbers(5)...).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Equal(t, totals.Records, int64(2), "records read")
+	assertion.True(t, totals.StoppedEarly, "End sees that the run stopped early")
+}
+
+func TestContext_Totals_Tail(t *testing.T) {
+	// Records skipped by TailRecords are read, and counted, all the same
+	var totals command.Totals
+	result := run.Command(command.Awk(ThroughputProgram{totals: &totals}, command.TailRecords(1))).
+		WithStdinLines("a", "b", "c").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"c", "6 bytes, 3 records, 1 emitted [=3]"})
+}
+
+func TestContext_Totals_OutsideRun(t *testing.T) {
+	totals := (&command.Context{}).Totals()
+	assertion.Equal(t, totals.Records, int64(0), "no records")
+	assertion.True(t, totals.Started.IsZero(), "not started")
+}
@@ -0,0 +1,70 @@
+package command
+
+import "strings"
+
+// Sub replaces the first match of the regular expression pattern in the field
+// (0 = $0, negative indexes count from the end) with repl, like awk's sub(),
+// and reports whether there was a match. In repl, & stands for the matched
+// text and \& for a literal &. Changing $0 splits it into fields again, and
+// changing a field rebuilds $0 with OFS. Patterns are compiled once per run
+// (see Regexp); an invalid pattern aborts the run.
+func (c *Context) Sub(pattern, repl string, field int) (replaced bool) {
+	return c.substitute(pattern, repl, field, 1) > 0
+}
+
+// Gsub replaces every match of pattern in the field like awk's gsub(), and
+// returns the number of replacements; see Sub. Matches do not overlap, and an
+// empty match next to a previous match is skipped, so Gsub("x*", "-", ...)
+// turns "abc" into "-a-b-c-".
+func (c *Context) Gsub(pattern, repl string, field int) (n int) {
+	return c.substitute(pattern, repl, field, -1)
+}
+
+// substitute replaces up to limit matches (all of them when negative)
+func (c *Context) substitute(pattern, repl string, field, limit int) int {
+	if fieldIndex(len(c.Fields), field) < 0 {
+		return 0
+	}
+	re, err := c.Regexp(pattern)
+	if err != nil {
+		c.Abort(err)
+		return 0
+	}
+	s := c.Field(field)
+	matches := re.FindAllStringIndex(s, limit)
+	if len(matches) == 0 {
+		return 0
+	}
+
+	var sb strings.Builder
+	last := 0
+	for _, m := range matches {
+		sb.WriteString(s[last:m[0]])
+		expand(&sb, repl, s[m[0]:m[1]])
+		last = m[1]
+	}
+	sb.WriteString(s[last:])
+
+	if field == 0 {
+		c.split(sb.String())
+	} else {
+		c.SetField(field, sb.String())
+	}
+	return len(matches)
+}
+
+// expand writes repl with each & replaced by match, \& by a literal & and
+// \\ by a backslash, as awk's sub() does
+func expand(sb *strings.Builder, repl, match string) {
+	for i := 0; i < len(repl); i++ {
+		switch {
+		case repl[i] == '&':
+			sb.WriteString(match)
+		case repl[i] == '\\' && i+1 < len(repl) && (repl[i+1] == '&' || repl[i+1] == '\\'):
+			i++
+			sb.WriteByte(repl[i])
+		default:
+			sb.WriteByte(repl[i])
+		}
+	}
+}
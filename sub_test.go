@@ -0,0 +1,102 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestContext_Gsub(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		repl    string
+		in      string
+		want    string
+		n       int
+	}{
+		{"every match", "o", "0", "foo boo", "f00 b00", 4},
+		{"no match", "z", "-", "abc", "abc", 0},
+		{"overlapping matches", "aa", "b", "aaaaa", "bba", 2},
+		{"empty matches", "x*", "-", "abc", "-a-b-c-", 4},
+		{"empty match after a match", "x*", "-", "xaxb", "-a-b-", 3},
+		{"empty input", "^", ">", "", ">", 1},
+		{"ampersand is the match", "[0-9]+", "<&>", "a1b22", "a<1>b<22>", 2},
+		{"escaped ampersand", "and", `\&`, "salt and pepper", "salt & pepper", 1},
+		{"escaped backslash", "b", `\\&`, "abc", `a\bc`, 1},
+		{"other backslashes kept", "b", `\n`, "abc", `a\nc`, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := &command.Context{Fields: []string{"x " + tt.in, "x", tt.in}, NF: 2, FS: " ", OFS: " "}
+			n := ctx.Gsub(tt.pattern, tt.repl, 2)
+			assertion.Equal(t, n, tt.n, "replacements")
+			assertion.Equal(t, ctx.Field(2), tt.want, "field")
+		})
+	}
+}
+
+func TestContext_Sub(t *testing.T) {
+	ctx := &command.Context{Fields: []string{"aa bb aa", "aa", "bb", "aa"}, NF: 3, FS: " ", OFS: "-"}
+
+	assertion.True(t, ctx.Sub("a", "&&", -1), "replaced")
+	assertion.Equal(t, ctx.Field(3), "aaa", "first match only")
+	assertion.Equal(t, ctx.Field(0), "aa-bb-aaa", "$0 rebuilt with OFS")
+
+	assertion.False(t, ctx.Sub("z", "y", 2), "no match")
+	assertion.Equal(t, ctx.Field(2), "bb", "field unchanged")
+
+	assertion.True(t, ctx.Sub("-bb-", " x y ", 0), "replaced in $0")
+	assertion.Equal(t, ctx.Field(0), "aa x y aaa", "$0")
+	assertion.Equal(t, ctx.FieldsSlice(), []string{"aa", "x", "y", "aaa"}, "$0 split again")
+	assertion.Equal(t, ctx.NF, 4, "NF")
+
+	assertion.False(t, ctx.Sub(".*", "y", -9), "field before $1")
+}
+
+// RedactProgram masks every digit of $2 and counts the masked digits, like
+// awk '{ n += gsub(/[0-9]/, "#", $2); print } END { print n }'
+type RedactProgram struct {
+	command.SimpleProgram
+	n *int
+}
+
+func (p RedactProgram) Action(ctx *command.Context) (string, bool) {
+	*p.n += ctx.Gsub("[0-9]", "#", 2)
+	return ctx.Field(0), true
+}
+
+func TestAwk_Gsub(t *testing.T) {
+	var n int
+	result := run.Command(command.Awk(RedactProgram{n: &n}, command.FieldSeparator(","))).
+		WithStdinLines("ann,555-1234", "bob,none").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"ann ###-####", "bob,none"})
+	assertion.Equal(t, n, 7, "replacements")
+}
+
+// BadPatternProgram calls Sub, or Match, with an invalid regular expression
+type BadPatternProgram struct {
+	command.SimpleProgram
+	match bool
+}
+
+func (p BadPatternProgram) Action(ctx *command.Context) (string, bool) {
+	if p.match {
+		ctx.Match(ctx.Field(0), "(")
+	} else {
+		ctx.Sub("(", "", 0)
+	}
+	return ctx.Field(0), true
+}
+
+func TestAwk_Sub_InvalidPattern(t *testing.T) {
+	result := run.Command(command.Awk(BadPatternProgram{})).WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "record 1: error parsing regexp")
+	assertion.Empty(t, result.Stdout)
+}
@@ -0,0 +1,76 @@
+package command
+
+// tailRing keeps the last records read, for TailRecords
+type tailRing struct {
+	records []tailRecord
+	next    int // index of the oldest record once the ring is full
+}
+
+type tailRecord struct {
+	fields   []string
+	text, rt string
+	nr, fnr  int64
+	name     string
+}
+
+func newTailRing(n TailRecords) *tailRing {
+	if n <= 0 {
+		return nil
+	}
+	return &tailRing{records: make([]tailRecord, 0, n)}
+}
+
+// push adds a record, dropping the oldest one when the ring is full
+func (t *tailRing) push(r tailRecord) {
+	if len(t.records) < cap(t.records) {
+		t.records = append(t.records, r)
+		return
+	}
+	t.records[t.next] = r
+	t.next = (t.next + 1) % len(t.records)
+}
+
+// all returns the records in input order
+func (t *tailRing) all() []tailRecord {
+	return append(t.records[t.next:], t.records[:t.next]...)
+}
+
+// replay feeds the records kept by TailRecords to the Program
+func (e *engine) replay() error {
+	if e.tail == nil {
+		return nil
+	}
+	records := e.tail.all()
+	e.ctx.getline = func() (string, bool) {
+		if len(records) == 0 {
+			return "", false
+		}
+		if e.ctx.lookback != nil {
+			e.ctx.lookback.push(e.seen)
+		}
+		r := records[0]
+		records = records[1:]
+		e.restore(r)
+		e.load(r.fields, r.text)
+		return e.ctx.Field(0), true
+	}
+	defer func() { e.ctx.getline = nil }()
+	for len(records) > 0 {
+		if err := e.ctx.Context().Err(); err != nil {
+			return err
+		}
+		r := records[0]
+		records = records[1:]
+		e.restore(r)
+		if err := e.process(r.fields, r.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restore sets the record number, input name and terminator of a kept record
+func (e *engine) restore(r tailRecord) {
+	e.ctx.NR, e.ctx.FNR, e.ctx.RT = r.nr, r.fnr, r.rt
+	e.name, e.ctx.Filename = r.name, r.name
+}
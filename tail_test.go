@@ -0,0 +1,56 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestAwk_TailRecords(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines int
+		want  []string
+	}{
+		{"shorter than n", 2, []string{"1: 1", "2: 2"}},
+		{"exactly n", 3, []string{"1: 1", "2: 2", "3: 3"}},
+		{"longer than n", 10000, []string{"9998: 9998", "9999: 9999", "10000: 10000"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := run.Command(command.Awk(LineNumberProgram{}, command.TailRecords(3))).
+				WithStdinLines(numbers(tt.lines)...).Run()
+
+			assertion.NoError(t, result.Err)
+			assertion.Lines(t, result.Stdout, tt.want)
+		})
+	}
+}
+
+func TestAwk_TailRecords_Files(t *testing.T) {
+	first := writeFile(t, "first.txt", "a", "b", "c")
+	second := writeFile(t, "second.txt", "d")
+
+	var stats command.Stats
+	result := run.Command(command.Awk(&CountingProgram{}, first, second,
+		command.TailRecords(2), command.StatsRecipient(&stats))).Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"Total lines: 2"})
+	assertion.Equal(t, stats.Records, int64(4), "every record is read")
+
+	result = run.Command(command.Awk(LineNumberProgram{}, first, second, command.TailRecords(2))).Run()
+	assertion.Lines(t, result.Stdout, []string{"3: c", "4: d"})
+}
+
+func TestAwk_TailRecords_Passthrough(t *testing.T) {
+	output := execute(t, command.SimpleProgram{}, "a\nb\r\nc", command.TailRecords(2))
+	assertion.Equal(t, output, "b\nc\n", "the fast path is not taken")
+}
+
+func TestAwkE_TailRecords(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, command.TailRecords(0))
+	assertion.ErrorContains(t, err, "invalid TailRecords 0: must be positive")
+}
@@ -0,0 +1,64 @@
+package command
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// TemplateData is what a Template is executed with for every record
+type TemplateData struct {
+	NR int64
+	NF int
+
+	// F are the fields, $0 first: {{index .F 2}} is $2
+	F []string
+
+	// Vars are the Program's variables
+	Vars map[string]any
+}
+
+// templateProgram is the Program of Template
+type templateProgram struct {
+	SimpleProgram
+	tmpl *template.Template
+}
+
+// Template returns a Program emitting every record rendered with the
+// text/template tmpl, executed with a TemplateData. Besides the builtin
+// functions, templates can call field (field 2 is $2, field -1 the last
+// field), upper, lower, trim and printf. It fails if tmpl does not parse; an
+// error executing it fails the run at the record.
+func Template(tmpl string) (Program, error) {
+	p := &templateProgram{}
+	t, err := template.New("awk").Funcs(template.FuncMap{
+		"field":  func(int) string { return "" }, // Bound to the Context of each run
+		"upper":  strings.ToUpper,
+		"lower":  strings.ToLower,
+		"trim":   strings.TrimSpace,
+		"printf": fmt.Sprintf,
+	}).Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	p.tmpl = t
+	return p, nil
+}
+
+// template returns the template of the current run, a copy of tmpl whose
+// field function reads the run's Context
+func (p *templateProgram) template(ctx *Context) *template.Template {
+	return ctx.state(p, func() any {
+		return template.Must(p.tmpl.Clone()).Funcs(template.FuncMap{"field": ctx.Field})
+	}).(*template.Template)
+}
+
+func (p *templateProgram) Action(ctx *Context) (string, bool) {
+	var b strings.Builder
+	data := TemplateData{NR: ctx.NR, NF: ctx.NF, F: ctx.Fields, Vars: ctx.Variables}
+	if err := p.template(ctx).Execute(&b, data); err != nil {
+		ctx.Abort(err)
+		return "", false
+	}
+	return b.String(), true
+}
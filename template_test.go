@@ -0,0 +1,49 @@
+package command_test
+
+import (
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestTemplate(t *testing.T) {
+	prog, err := command.Template(`{{.NR}}/{{.NF}}: {{field 2}} owes {{index .F 1 | upper}} {{printf "%.2f" 12.5}} {{.Vars.currency}}`)
+	assertion.NoError(t, err)
+
+	result := run.Command(command.Awk(prog, command.Variable{Name: "currency", Value: "EUR"})).
+		WithStdinLines("alice bob", "carol dave x").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{
+		"1/2: bob owes ALICE 12.50 EUR",
+		"2/3: dave owes CAROL 12.50 EUR",
+	})
+}
+
+func TestTemplate_Fields(t *testing.T) {
+	prog, err := command.Template(`{{field -1}},{{field 1 | lower}},{{field 9}},[{{trim (index .F 0)}}]`)
+	assertion.NoError(t, err)
+
+	output := execute(t, prog, "One Two Three\n")
+	assertion.Equal(t, output, "Three,one,,[One Two Three]\n", "fields")
+}
+
+func TestTemplate_ParseError(t *testing.T) {
+	_, err := command.Template(`{{.NR`)
+	assertion.ErrorContains(t, err, "unclosed action")
+
+	_, err = command.Template(`{{nosuchfunc 1}}`)
+	assertion.ErrorContains(t, err, `function "nosuchfunc" not defined`)
+}
+
+func TestTemplate_ExecError(t *testing.T) {
+	prog, err := command.Template(`{{index .F 3}}`)
+	assertion.NoError(t, err)
+
+	result := run.Command(command.Awk(prog)).WithStdinLines("a b c", "a").Run()
+	assertion.ErrorContains(t, result.Err, "record 2: ")
+	assertion.ErrorContains(t, result.Err, "index out of range")
+	assertion.Lines(t, result.Stdout, []string{"c"})
+}
@@ -0,0 +1,67 @@
+package command
+
+import (
+	"fmt"
+	"io"
+)
+
+// tracer writes a debugging line for BEGIN, END and every sampled record
+type tracer struct {
+	w      io.Writer
+	every  int64
+	prefix string
+
+	// records counts the records traced or skipped by sampling
+	records int64
+}
+
+// newTracer returns a tracer writing to w, or nil when tracing is off
+func newTracer(w io.Writer, every TraceEvery, stage string) *tracer {
+	if every <= 0 || w == nil {
+		return nil
+	}
+	prefix := "awk: "
+	if stage != "" {
+		prefix += stage + ": "
+	}
+	return &tracer{w: w, every: int64(every), prefix: prefix}
+}
+
+func (t *tracer) printf(format string, args ...any) {
+	_, _ = fmt.Fprintf(t.w, t.prefix+format+"\n", args...)
+}
+
+// begin traces the Program's Begin
+func (t *tracer) begin(err error) {
+	if t == nil {
+		return
+	}
+	if err != nil {
+		t.printf("BEGIN error: %v", err)
+		return
+	}
+	t.printf("BEGIN")
+}
+
+// record traces the current record if it is sampled
+func (t *tracer) record(ctx *Context, cond, emit bool) {
+	if t == nil {
+		return
+	}
+	if t.records++; t.records%t.every != 0 {
+		return
+	}
+	t.printf("NR=%d NF=%d cond=%t emit=%t bytes=%d", ctx.NR, ctx.NF, cond, emit, len(ctx.Field(0)))
+}
+
+// end traces the Program's End
+func (t *tracer) end(ctx *Context, emit bool, err error) {
+	if t == nil {
+		return
+	}
+	if err != nil {
+		t.printf("END NR=%d error: %v", ctx.NR, err)
+		return
+	}
+	t.printf("END NR=%d emit=%t", ctx.NR, emit)
+}
@@ -0,0 +1,87 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+func TestAwk_Trace(t *testing.T) {
+	result := run.Command(command.Awk(ConditionalProgram{}, command.Trace())).
+		WithStdinLines("include:a b", "skip", "include:c").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"include:a b", "include:c"})
+	assertion.Lines(t, result.Stderr, []string{
+		"awk: BEGIN",
+		"awk: NR=1 NF=2 cond=true emit=true bytes=11",
+		"awk: NR=2 NF=1 cond=false emit=false bytes=4",
+		"awk: NR=3 NF=1 cond=true emit=true bytes=9",
+		"awk: END NR=3 emit=false",
+	})
+}
+
+func TestAwk_TraceEvery(t *testing.T) {
+	result := run.Command(command.Awk(command.SimpleProgram{}, command.TraceEvery(2))).
+		WithStdinLines("a", "b", "c", "d", "e").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a", "b", "c", "d", "e"})
+	assertion.Lines(t, result.Stderr, []string{
+		"awk: BEGIN",
+		"awk: NR=2 NF=1 cond=true emit=true bytes=1",
+		"awk: NR=4 NF=1 cond=true emit=true bytes=1",
+		"awk: END NR=5 emit=false",
+	})
+}
+
+func TestAwk_Trace_Errors(t *testing.T) {
+	result := run.Command(command.Awk(ErrorInBeginProgram{}, command.Trace())).
+		WithStdinLines("a").Run()
+
+	assertion.Error(t, result.Err)
+	assertion.Lines(t, result.Stderr, []string{"awk: BEGIN error: begin error"})
+
+	result = run.Command(command.Awk(ErrorInEndProgram{}, command.Trace())).Run()
+
+	assertion.Error(t, result.Err)
+	assertion.Lines(t, result.Stderr, []string{"awk: BEGIN", "awk: END NR=0 error: end error"})
+}
+
+func TestAwk_Trace_Off(t *testing.T) {
+	result := run.Command(command.Awk(ConditionalProgram{})).
+		WithStdinLines("include:a", "skip").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Empty(t, result.Stderr)
+}
+
+func TestAwk_Trace_Pipe(t *testing.T) {
+	cmd := command.Pipe(ConditionalProgram{}, command.Stage(UppercaseProgram{}, command.Trace()))
+	result := run.Command(cmd).WithStdinLines("include:a", "skip").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stderr, []string{
+		"awk: stage 2: BEGIN",
+		"awk: stage 2: NR=1 NF=1 cond=true emit=true bytes=9",
+		"awk: stage 2: END NR=1 emit=false",
+	})
+}
+
+func TestAwk_Trace_SameOutput(t *testing.T) {
+	input := "a\r\nb c\nd"
+	for _, prog := range []command.Program{command.SimpleProgram{}, UppercaseProgram{}} {
+		var plain, traced, stderr bytes.Buffer
+		err := command.Awk(prog).Executor()(context.Background(), strings.NewReader(input), &plain, &stderr)
+		assertion.NoError(t, err)
+		err = command.Awk(prog, command.Trace()).Executor()(context.Background(), strings.NewReader(input), &traced, &stderr)
+		assertion.NoError(t, err)
+
+		assertion.Equal(t, traced.String(), plain.String(), "tracing does not change stdout")
+	}
+}
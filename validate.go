@@ -0,0 +1,172 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	gloo "github.com/gloo-foo/framework"
+	"github.com/yupsh/awk/internal/charset"
+)
+
+// validate returns an error listing every parameter of a command that is
+// unknown or invalid, or nil
+func validate(parameters []any, stages ...Program) error {
+	errs := problems(parameters)
+	for i, program := range stages {
+		s, ok := program.(stage)
+		if !ok {
+			continue
+		}
+		for _, parameter := range s.parameters {
+			switch parameter.(type) {
+			case string, gloo.File, io.Reader:
+				errs = append(errs, fmt.Errorf("stage %d: input %v must be given to Pipe, not Stage", i+1, parameter))
+			case Source, Sources:
+				errs = append(errs, fmt.Errorf("stage %d: Sources must be given to Pipe, not Stage", i+1))
+			case fromSource, fromChannel:
+				errs = append(errs, fmt.Errorf("stage %d: FromSource and FromChannel must be given to Pipe, not Stage", i+1))
+			case emitFunc, emitChannel:
+				errs = append(errs, fmt.Errorf("stage %d: EmitFunc and EmitChannel must be given to Pipe, not Stage", i+1))
+			}
+		}
+		for _, err := range problems(s.parameters) {
+			errs = append(errs, fmt.Errorf("stage %d: %w", i+1, err))
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid parameters:\n%w", errors.Join(errs...))
+}
+
+// problems returns the unknown parameters and invalid flag values
+func problems(parameters []any) []error {
+	var (
+		errs                []error
+		files               bool
+		separator, splitter bool
+	)
+	for _, parameter := range parameters {
+		switch p := parameter.(type) {
+		case string, gloo.File:
+			files = true
+		case RecordSeparator:
+			separator = true
+			if p == "" {
+				errs = append(errs, errParagraphMode)
+			}
+		case Variable:
+			switch p.Name {
+			case "NR", "NF":
+				errs = append(errs, fmt.Errorf("invalid Variable %s: set by the engine for every record", p.Name))
+			case "RS":
+				separator = true
+				if fmt.Sprint(p.Value) == "" {
+					errs = append(errs, errParagraphMode)
+				}
+			}
+		case RecordSplitter:
+			splitter = p != nil
+			if p == nil {
+				errs = append(errs, errors.New("invalid RecordSplitter: nil"))
+			}
+		case ReadBufferSize:
+			errs = append(errs, positive("ReadBufferSize", int(p))...)
+		case WriteBufferSize:
+			errs = append(errs, positive("WriteBufferSize", int(p))...)
+		case MaxRecordSize:
+			errs = append(errs, positive("MaxRecordSize", int(p))...)
+		case SpoolLimit:
+			errs = append(errs, positive("SpoolLimit", int(p))...)
+		case RegexpCacheSize:
+			errs = append(errs, positive("RegexpCacheSize", int(p))...)
+		case MaxOutputBytes:
+			errs = append(errs, positive("MaxOutputBytes", int(p))...)
+		case Lookback:
+			errs = append(errs, positive("Lookback", int(p))...)
+		case TailRecords:
+			errs = append(errs, positive("TailRecords", int(p))...)
+		case InputEncoding:
+			if _, ok := charset.Lookup(string(p)); !ok {
+				errs = append(errs, fmt.Errorf("unknown InputEncoding %q", string(p)))
+			}
+		case OutputEncoding:
+			if _, ok := charset.Lookup(string(p)); !ok {
+				errs = append(errs, fmt.Errorf("unknown OutputEncoding %q", string(p)))
+			}
+		case Decompressor:
+			if p.NewReader == nil || (p.Extension == "" && len(p.Magic) == 0) {
+				errs = append(errs, fmt.Errorf("invalid Decompressor %q: needs NewReader and an Extension or Magic", p.Extension))
+			}
+		case fromSource:
+			if p.src == nil {
+				errs = append(errs, errors.New("invalid FromSource: nil"))
+			}
+		case fromChannel:
+			if p.ch == nil {
+				errs = append(errs, errors.New("invalid FromChannel: nil"))
+			}
+		case emitFunc:
+			if p == nil {
+				errs = append(errs, errors.New("invalid EmitFunc: nil"))
+			}
+		case emitChannel:
+			if p == nil {
+				errs = append(errs, errors.New("invalid EmitChannel: nil"))
+			}
+		case observe:
+			if p.observer == nil {
+				errs = append(errs, errors.New("invalid Observe: nil Observer"))
+			}
+		case namedOutput:
+			if p.name == "" || p.w == nil {
+				errs = append(errs, fmt.Errorf("invalid NamedOutput %q: needs a name and a writer", p.name))
+			}
+		case OutputFiles:
+			if p == "" {
+				errs = append(errs, errors.New("invalid OutputFiles: needs a directory"))
+			}
+		case io.Reader, gloo.Switch[flags]:
+		default:
+			errs = append(errs, fmt.Errorf("unknown parameter %v of type %T", p, p))
+		}
+	}
+
+	if separator && splitter {
+		errs = append(errs, errors.New("RecordSplitter cannot be combined with RecordSeparator"))
+	}
+
+	f := configure(parameters)
+	if files && len(f.Sources) > 0 {
+		errs = append(errs, errors.New("Sources cannot be combined with file operands"))
+	}
+	if f.RecordSource != nil && (files || len(f.Sources) > 0) {
+		errs = append(errs, errors.New("FromSource cannot be combined with file operands or Sources"))
+	}
+	if f.EmitFunc != nil && f.EmitChannel != nil {
+		errs = append(errs, errors.New("EmitFunc cannot be combined with EmitChannel"))
+	}
+	if f.RecordChannel != nil && (files || len(f.Sources) > 0 || f.RecordSource != nil) {
+		errs = append(errs, errors.New("FromChannel cannot be combined with file operands, Sources or FromSource"))
+	}
+	if _, err := newRecordSplitter(string(f.RecordSeparator)); err != nil {
+		errs = append(errs, err)
+	}
+	if s := fmt.Sprintf(string(f.OutputFormat), 1.5); strings.Contains(s, "%!") {
+		errs = append(errs, fmt.Errorf("invalid output format %q: %s", f.OutputFormat, s))
+	}
+	if f.StartNR < 0 {
+		errs = append(errs, fmt.Errorf("invalid StartNR %d: must not be negative", f.StartNR))
+	}
+	return errs
+}
+
+// positive reports a size that is not positive
+func positive(name string, n int) []error {
+	if n <= 0 {
+		return []error{fmt.Errorf("invalid %s %d: must be positive", name, n)}
+	}
+	return nil
+}
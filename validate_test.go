@@ -0,0 +1,94 @@
+package command_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+type bogusOption struct{}
+
+func TestAwkE_Valid(t *testing.T) {
+	cmd, err := command.AwkE(command.SimpleProgram{},
+		command.FieldSeparator(","),
+		command.RecordSeparator(`\n+`),
+		command.OutputFormat("%.2f"),
+		command.Variable{Name: "x", Value: 1},
+	)
+
+	assertion.NoError(t, err)
+	result := run.Command(cmd).WithStdinLines("a").Run()
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a"})
+}
+
+func TestAwkE_Unknown(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{}, 42, bogusOption{})
+
+	assertion.ErrorContains(t, err, "unknown parameter 42 of type int")
+	assertion.ErrorContains(t, err, "unknown parameter {} of type command_test.bogusOption")
+}
+
+// FieldSeparator mimics an option of another package: it configures flags,
+// but not the command's
+type FieldSeparator string
+
+type foreignFlags struct{ FieldSeparator FieldSeparator }
+
+func (f FieldSeparator) Configure(flags *foreignFlags) { flags.FieldSeparator = f }
+
+func TestAwk_ForeignOption(t *testing.T) {
+	result := run.Command(command.Awk(FieldCountProgram{}, FieldSeparator(","))).
+		WithStdinLines("a,b").Run()
+
+	assertion.ErrorContains(t, result.Err, "unknown parameter , of type command_test.FieldSeparator")
+	assertion.Equal(t, len(result.Stdout), 0, "the input is not processed with the wrong separator")
+
+	result = run.Command(command.Pipe(FieldCountProgram{}, command.Stage(LineNumberProgram{}, FieldSeparator(",")))).
+		WithStdinLines("a,b").Run()
+	assertion.ErrorContains(t, result.Err, "stage 2: unknown parameter , of type command_test.FieldSeparator")
+}
+
+func TestAwkE_InvalidValues(t *testing.T) {
+	_, err := command.AwkE(command.SimpleProgram{},
+		command.RecordSeparator("(["),
+		command.OutputFormat("%d"),
+		command.StartNR(-1),
+	)
+
+	assertion.ErrorContains(t, err, `invalid record separator "(["`)
+	assertion.ErrorContains(t, err, `invalid output format "%d"`)
+	assertion.ErrorContains(t, err, "invalid StartNR -1")
+}
+
+func TestAwk_Invalid_FailsBeforeReading(t *testing.T) {
+	prog := &CountingProgram{}
+	cmd := command.Awk(prog, command.RecordSeparator("(["))
+
+	var stdout, stderr bytes.Buffer
+	stdin := strings.NewReader("a\nb\n")
+	err := cmd.Executor()(context.Background(), stdin, &stdout, &stderr)
+
+	assertion.ErrorContains(t, err, "invalid parameters")
+	assertion.Equal(t, stdin.Len(), 4, "no input is read")
+	assertion.Equal(t, prog.count, 0, "the Program is not run")
+}
+
+func TestPipe_Invalid(t *testing.T) {
+	cmd := command.Pipe(
+		command.Stage(command.SimpleProgram{}, "data.txt"),
+		command.Stage(command.SimpleProgram{}, command.OutputFormat("%d")),
+		3.5,
+	)
+	result := run.Command(cmd).WithStdinLines("a").Run()
+
+	assertion.ErrorContains(t, result.Err, "unknown parameter 3.5 of type float64")
+	assertion.ErrorContains(t, result.Err, "stage 1: input data.txt must be given to Pipe, not Stage")
+	assertion.ErrorContains(t, result.Err, `stage 2: invalid output format "%d"`)
+	assertion.Empty(t, result.Stdout)
+}
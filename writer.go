@@ -0,0 +1,87 @@
+package command
+
+import (
+	"bytes"
+	"io"
+)
+
+// ActionWriter is implemented by Programs that write large outputs for a
+// record straight to the output instead of returning them from Action, which
+// is then not called. The Program writes its records with their terminators:
+// the engine adds nothing. emitted reports whether it wrote a record, for
+// statistics and tracing; an error fails the run.
+//
+// Inside a Pipe, the output of the first stage is split into newline
+// terminated records for the second, and inside Rules the records are output
+// like the other rules' records.
+type ActionWriter interface {
+	ActionWriter(ctx *Context, w io.Writer) (emitted bool, err error)
+}
+
+// writeAction runs the Program's ActionWriter for the current record
+func (e *engine) writeAction(aw ActionWriter) (bool, error) {
+	var w io.Writer = io.Discard
+	if !e.suppress {
+		w = countingWriter{w: e.out, e: e}
+	}
+	emitted, err := aw.ActionWriter(e.ctx, w)
+	if emitted && !e.suppress {
+		e.observeEmit()
+	}
+	if err != nil {
+		return emitted, e.recordError(err)
+	}
+	return emitted, nil
+}
+
+// countingWriter counts the bytes written through it as output of e, and
+// fails the writes MaxOutputBytes refuses
+type countingWriter struct {
+	w io.Writer
+	e *engine
+}
+
+func (c countingWriter) Write(p []byte) (int, error) {
+	if err := c.e.outputLimit(len(p)); err != nil {
+		return 0, err
+	}
+	n, err := c.w.Write(p)
+	c.e.wrote(n)
+	return n, err
+}
+
+// lineWriter splits what is written to it into newline terminated records
+// for emit. flush emits a final unterminated record.
+type lineWriter struct {
+	emit    func(record, terminator string) error
+	pending []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	data := p
+	if len(l.pending) > 0 {
+		data = append(l.pending, p...)
+		l.pending = nil
+	}
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		if err := l.emit(string(data[:i]), "\n"); err != nil {
+			return 0, err
+		}
+		data = data[i+1:]
+	}
+	l.pending = append(l.pending, data...)
+	return len(p), nil
+}
+
+func (l *lineWriter) flush() error {
+	if len(l.pending) == 0 {
+		return nil
+	}
+	record := string(l.pending)
+	l.pending = nil
+	return l.emit(record, "")
+}
@@ -0,0 +1,85 @@
+package command_test
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/gloo-foo/testable/assertion"
+	"github.com/gloo-foo/testable/run"
+	command "github.com/yupsh/awk"
+)
+
+// ExplodeProgram writes $1 copies of $2, one per line, straight to the output
+type ExplodeProgram struct {
+	command.SimpleProgram
+}
+
+func (p ExplodeProgram) ActionWriter(ctx *command.Context, w io.Writer) (bool, error) {
+	n, err := strconv.Atoi(ctx.Field(1))
+	if err != nil {
+		return false, err
+	}
+	for i := range n {
+		if _, err := fmt.Fprintf(w, "%s %d\n", ctx.Field(2), i+1); err != nil {
+			return true, err
+		}
+	}
+	return n > 0, nil
+}
+
+func (p ExplodeProgram) End(ctx *command.Context) (string, error) {
+	return "done", nil
+}
+
+func TestAwk_ActionWriter(t *testing.T) {
+	var stats command.Stats
+	result := run.Command(command.Awk(ExplodeProgram{}, command.StatsRecipient(&stats))).
+		WithStdinLines("2 a", "0 b", "3 c").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"a 1", "a 2", "c 1", "c 2", "c 3", "done"})
+	assertion.Equal(t, stats.Emitted, int64(3), "records that wrote output, and End")
+	assertion.Equal(t, stats.BytesWritten, int64(len("a 1\na 2\nc 1\nc 2\nc 3\ndone\n")), "bytes written")
+}
+
+func TestAwk_ActionWriter_Error(t *testing.T) {
+	result := run.Command(command.Awk(ExplodeProgram{})).
+		WithStdinLines("1 a", "x b").Run()
+
+	assertion.ErrorContains(t, result.Err, `record 2: strconv.Atoi: parsing "x"`)
+	assertion.Lines(t, result.Stdout, []string{"a 1"})
+}
+
+func TestAwk_ActionWriter_Large(t *testing.T) {
+	output := execute(t, ExplodeProgram{}, "100000 x\n")
+	assertion.Equal(t, strings.Count(output, "\n"), 100001, "all lines written")
+}
+
+func TestPipe_ActionWriter(t *testing.T) {
+	result := run.Command(command.Pipe(ExplodeProgram{}, LineNumberProgram{})).
+		WithStdinLines("2 a", "1 b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{"1: a 1", "2: a 2", "3: b 1", "4: done"})
+}
+
+// PartialWriterProgram writes its record without a terminator
+type PartialWriterProgram struct {
+	command.SimpleProgram
+}
+
+func (p PartialWriterProgram) ActionWriter(ctx *command.Context, w io.Writer) (bool, error) {
+	_, err := io.WriteString(w, ctx.Field(0)+";")
+	return true, err
+}
+
+func TestPipe_ActionWriter_Unterminated(t *testing.T) {
+	result := run.Command(command.Pipe(PartialWriterProgram{}, TerminatorProgram{})).
+		WithStdinLines("a", "b").Run()
+
+	assertion.NoError(t, result.Err)
+	assertion.Lines(t, result.Stdout, []string{`1 [a;b;] RT=""`})
+}